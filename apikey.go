@@ -0,0 +1,347 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TenantKey is the context key holding the caller's tenant.
+	TenantKey = "tenant"
+	// APIKeyKey is the context key holding the authenticated *APIKey.
+	APIKeyKey = "api_key"
+)
+
+// ErrAPIKeyNotFound is returned by a KeyStore when no key matches.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// invalidAPIKeyLabel is the metric label of rejected keys. Key IDs cannot
+// start with "_", so it never collides with a registered key.
+const invalidAPIKeyLabel = "_invalid"
+
+var (
+	errMissingAPIKey = newHTTPError(http.StatusUnauthorized, "missing API key")
+	errInvalidAPIKey = newHTTPError(http.StatusUnauthorized, "invalid API key")
+)
+
+// APIKey is a registered API key. Only the SHA-256 hash of the key is kept.
+type APIKey struct {
+	// ID identifies the key in logs, metrics and quotas. It must not be
+	// secret, must be unique within a store and must not start with "_",
+	// which is reserved for metric labels such as "_invalid".
+	ID string
+	// Hash is the hex-encoded SHA-256 of the key, as built by HashAPIKey.
+	Hash string
+	// Owner becomes the request's Subject.
+	Owner  string
+	Tenant string
+	Scopes []string
+	// Quota is the number of requests allowed per QuotaWindow. Zero means
+	// unlimited.
+	Quota int
+	// QuotaWindow defaults to 24 hours.
+	QuotaWindow time.Duration
+	// ExpiresAt, when set, rejects the key after that time.
+	ExpiresAt time.Time
+	Disabled  bool
+}
+
+func (k *APIKey) usable(now time.Time) bool {
+	return !k.Disabled && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 of key, the form stored in
+// APIKey.Hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyStore looks up API keys by hash. Implementations must compare hashes in
+// constant time and be safe for concurrent use.
+type KeyStore interface {
+	// Lookup returns the key whose Hash equals hash, or ErrAPIKeyNotFound.
+	Lookup(ctx stdcontext.Context, hash string) (*APIKey, error)
+}
+
+// APIKeyConfig configures the API key authentication middleware.
+type APIKeyConfig struct {
+	// Header carries the key. Defaults to "X-Api-Key".
+	Header string
+	// QueryParam, when set, is checked when the header is absent.
+	QueryParam string
+	// Store holds the registered keys. Required.
+	Store KeyStore
+	// QuotaStore counts per-key usage for quotas. Defaults to a new
+	// in-memory store; use a shared store to enforce quotas across replicas.
+	QuotaStore RateLimitStore
+}
+
+// APIKeyAuth returns a middleware authenticating requests by API key. The
+// key's owner, tenant and scopes are exposed through Context.Subject,
+// Context.Tenant and Context.HasScope, so authorization middleware works the
+// same as with JWT. Unknown, disabled or expired keys fail with 401 and keys
+// over their quota with 429. It panics when Store is nil.
+func APIKeyAuth(cfg APIKeyConfig) MiddlewareFunc {
+	if cfg.Store == nil {
+		panic("echoext: APIKeyAuth requires a Store")
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = "X-Api-Key"
+	}
+
+	if cfg.QuotaStore == nil {
+		cfg.QuotaStore = NewMemoryRateLimitStore()
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			raw := c.Request().Header.Get(cfg.Header)
+			if raw == "" && cfg.QueryParam != "" {
+				raw = c.QueryParam(cfg.QueryParam)
+			}
+
+			if raw == "" {
+				return errMissingAPIKey
+			}
+
+			key, err := cfg.Store.Lookup(c.Request().Context(), HashAPIKey(raw))
+			if err != nil || !key.usable(time.Now()) {
+				if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+					c.Logger().Errorf("api key store: %v", err)
+				}
+
+				apiKeyRequests.WithLabelValues(invalidAPIKeyLabel, "rejected").Inc()
+				return errInvalidAPIKey
+			}
+
+			if key.Quota > 0 {
+				if err := enforceQuota(c, cfg.QuotaStore, key); err != nil {
+					apiKeyRequests.WithLabelValues(key.ID, "quota_exceeded").Inc()
+					return err
+				}
+			}
+
+			apiKeyRequests.WithLabelValues(key.ID, "allowed").Inc()
+
+			c.Set(APIKeyKey, key)
+			c.Set(SubjectKey, key.Owner)
+			c.Set(TenantKey, key.Tenant)
+			c.Set(ScopesKey, key.Scopes)
+
+			return next(c)
+		}
+	}
+}
+
+// enforceQuota consumes one request from the key's quota, setting the
+// RateLimit-* headers. Store errors are logged and let the request through.
+func enforceQuota(c Context, store RateLimitStore, key *APIKey) error {
+	window := key.QuotaWindow
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+
+	rule := RateLimitRule{Algorithm: SlidingWindow, Limit: key.Quota, Window: window, Burst: key.Quota}
+
+	res, err := store.Take(c.Request().Context(), "apikey:"+key.ID, rule)
+	if err != nil {
+		c.Logger().Errorf("api key quota store: %v", err)
+		return nil
+	}
+
+	h := c.Response().Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		return newHTTPError(http.StatusTooManyRequests, "API key quota exceeded")
+	}
+
+	return nil
+}
+
+// MemoryKeyStore is an in-process KeyStore.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys []*APIKey
+}
+
+// NewMemoryKeyStore creates a store holding keys. It panics when a key ID is
+// empty, reserved or duplicated.
+func NewMemoryKeyStore(keys ...*APIKey) *MemoryKeyStore {
+	if err := validateAPIKeys(keys); err != nil {
+		panic("echoext: " + err.Error())
+	}
+
+	return &MemoryKeyStore{keys: keys}
+}
+
+// validateAPIKeys checks that every key has a unique, unreserved ID, since
+// IDs label metrics and key quotas.
+func validateAPIKeys(keys []*APIKey) error {
+	seen := make(map[string]bool, len(keys))
+	for i, k := range keys {
+		switch {
+		case k.ID == "":
+			return fmt.Errorf("api key %d: ID is required", i)
+		case strings.HasPrefix(k.ID, "_"):
+			return fmt.Errorf("api key %q: IDs starting with _ are reserved", k.ID)
+		case seen[k.ID]:
+			return fmt.Errorf("api key %q: duplicate ID", k.ID)
+		}
+
+		seen[k.ID] = true
+	}
+
+	return nil
+}
+
+// Lookup implements KeyStore. Every stored hash is compared so the time taken
+// does not depend on which key matched.
+func (s *MemoryKeyStore) Lookup(_ stdcontext.Context, hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *APIKey
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			found = k
+		}
+	}
+
+	if found == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return found, nil
+}
+
+// Set replaces the stored keys. Keys with an empty, reserved or duplicated
+// ID are rejected and the previous keys kept.
+func (s *MemoryKeyStore) Set(keys ...*APIKey) error {
+	if err := validateAPIKeys(keys); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+
+	return nil
+}
+
+// fileKeyStoreCheckInterval bounds how often a FileKeyStore stats its file.
+const fileKeyStoreCheckInterval = 5 * time.Second
+
+// FileKeyStore is a KeyStore loaded from a JSON file and reloaded when the
+// file changes. The file holds an array of objects with the fields "id",
+// "hash", "owner", "tenant", "scopes", "quota", "quota_window" (a Go
+// duration such as "24h"), "expires_at" (RFC 3339) and "disabled".
+type FileKeyStore struct {
+	path string
+	mem  *MemoryKeyStore
+
+	mu        sync.Mutex
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewFileKeyStore loads the keys in path.
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path, mem: NewMemoryKeyStore()}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Lookup implements KeyStore. A failed reload keeps the previous keys.
+func (s *FileKeyStore) Lookup(ctx stdcontext.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	if time.Since(s.checkedAt) > fileKeyStoreCheckInterval {
+		s.checkedAt = time.Now()
+		if fi, err := os.Stat(s.path); err == nil && !fi.ModTime().Equal(s.modTime) {
+			_ = s.reload()
+		}
+	}
+	s.mu.Unlock()
+
+	return s.mem.Lookup(ctx, hash)
+}
+
+// reload reads the file into the memory store. Callers must hold s.mu or
+// own s exclusively.
+func (s *FileKeyStore) reload() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+
+	var entries []struct {
+		ID          string    `json:"id"`
+		Hash        string    `json:"hash"`
+		Owner       string    `json:"owner"`
+		Tenant      string    `json:"tenant"`
+		Scopes      []string  `json:"scopes"`
+		Quota       int       `json:"quota"`
+		QuotaWindow string    `json:"quota_window"`
+		ExpiresAt   time.Time `json:"expires_at"`
+		Disabled    bool      `json:"disabled"`
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+
+	keys := make([]*APIKey, 0, len(entries))
+	for _, e := range entries {
+		var window time.Duration
+		if e.QuotaWindow != "" {
+			if window, err = time.ParseDuration(e.QuotaWindow); err != nil {
+				return fmt.Errorf("api key file: key %q: %w", e.ID, err)
+			}
+		}
+
+		keys = append(keys, &APIKey{
+			ID:          e.ID,
+			Hash:        strings.ToLower(e.Hash),
+			Owner:       e.Owner,
+			Tenant:      e.Tenant,
+			Scopes:      e.Scopes,
+			Quota:       e.Quota,
+			QuotaWindow: window,
+			ExpiresAt:   e.ExpiresAt,
+			Disabled:    e.Disabled,
+		})
+	}
+
+	if err := s.mem.Set(keys...); err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+
+	s.modTime = fi.ModTime()
+
+	return nil
+}
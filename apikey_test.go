@@ -0,0 +1,73 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMemoryKeyStoreSetValidatesIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []*APIKey
+		wantErr bool
+	}{
+		{name: "unique IDs", keys: []*APIKey{{ID: "a"}, {ID: "b"}}},
+		{name: "empty ID", keys: []*APIKey{{ID: "a"}, {ID: ""}}, wantErr: true},
+		{name: "duplicate ID", keys: []*APIKey{{ID: "a"}, {ID: "a"}}, wantErr: true},
+		{name: "reserved ID", keys: []*APIKey{{ID: invalidAPIKeyLabel}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewMemoryKeyStore().Set(tt.keys...); (err != nil) != tt.wantErr {
+				t.Fatalf("Set() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := NewMemoryKeyStore(
+		&APIKey{ID: "partner", Hash: HashAPIKey("secret"), Owner: "partner-integration"},
+		&APIKey{ID: "old", Hash: HashAPIKey("disabled"), Owner: "old", Disabled: true},
+	)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{name: "valid", key: "secret", wantStatus: http.StatusOK},
+		{name: "missing", wantStatus: http.StatusUnauthorized},
+		{name: "unknown", key: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "disabled", key: "disabled", wantStatus: http.StatusUnauthorized},
+	}
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("partners", func(g *Group) {
+		g.GET("", func(c Context) error {
+			return c.String(http.StatusOK, c.Subject())
+		})
+	}, APIKeyAuth(APIKeyConfig{Store: store}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/partners", nil)
+			if tt.key != "" {
+				req.Header.Set("X-Api-Key", tt.key)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != "partner-integration" {
+				t.Fatalf("subject = %q", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package echoext
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RolesKey is the context key holding the principal's roles as []string.
+const RolesKey = "roles"
+
+var errForbidden = newHTTPError(http.StatusForbidden, "forbidden")
+
+// Principal is the authenticated caller as seen by authorization checks.
+type Principal struct {
+	Subject string
+	Scopes  []string
+	Roles   []string
+	Claims  Claims
+}
+
+// Policy decides whether the principal may perform the request.
+type Policy func(p Principal, r *http.Request) bool
+
+// Requirement is an authorization rule attached to routes with
+// Group.Require. A request must satisfy every requirement of its route.
+type Requirement struct {
+	// Scopes must all be granted.
+	Scopes []string
+	// Roles requires at least one of the listed roles.
+	Roles []string
+	// Policy names the policy function, for the route table and API docs.
+	Policy string
+
+	policy Policy
+}
+
+// String renders the requirement for the route table and API docs.
+func (r Requirement) String() string {
+	switch {
+	case len(r.Scopes) > 0:
+		return "scopes: " + strings.Join(r.Scopes, ", ")
+	case len(r.Roles) > 0:
+		return "roles: " + strings.Join(r.Roles, " | ")
+	default:
+		return "policy: " + r.Policy
+	}
+}
+
+func (r Requirement) allows(p Principal, req *http.Request) bool {
+	for _, s := range r.Scopes {
+		if !slices.Contains(p.Scopes, s) {
+			return false
+		}
+	}
+
+	if len(r.Roles) > 0 && !slices.ContainsFunc(r.Roles, func(role string) bool {
+		return slices.Contains(p.Roles, role)
+	}) {
+		return false
+	}
+
+	if r.policy != nil && !r.policy(p, req) {
+		return false
+	}
+
+	return true
+}
+
+// RequireScopes returns a requirement allowing only principals granted all
+// of scopes.
+func RequireScopes(scopes ...string) Requirement {
+	return Requirement{Scopes: scopes}
+}
+
+// RequireRoles returns a requirement allowing only principals holding at
+// least one of roles.
+func RequireRoles(roles ...string) Requirement {
+	return Requirement{Roles: roles}
+}
+
+// RequirePolicy returns a requirement allowing only requests for which policy
+// returns true. name identifies the policy in the route table and API docs.
+func RequirePolicy(name string, policy Policy) Requirement {
+	return Requirement{Policy: name, policy: policy}
+}
+
+// authorize enforces req after authentication. Anonymous requests fail with
+// 401 and authenticated ones that do not satisfy req with 403.
+func authorize(req Requirement) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			p := c.Principal()
+			if p.Subject == "" {
+				return newHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+
+			if !req.allows(p, c.Request()) {
+				return errForbidden
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeAuth authenticates requests carrying "X-Subject", granting the scopes
+// and roles listed in "X-Scopes" and "X-Roles".
+func fakeAuth(next HandlerFunc) HandlerFunc {
+	return func(c Context) error {
+		h := c.Request().Header
+		if sub := h.Get("X-Subject"); sub != "" {
+			c.Set(SubjectKey, sub)
+			c.Set(ScopesKey, strings.Fields(h.Get("X-Scopes")))
+			c.Set(RolesKey, strings.Fields(h.Get("X-Roles")))
+		}
+
+		return next(c)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	ownsStore := func(p Principal, r *http.Request) bool {
+		return strings.HasSuffix(r.URL.Path, "/"+p.Subject)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		method     string
+		subject    string
+		scopes     string
+		roles      string
+		wantStatus int
+	}{
+		{name: "anonymous", method: http.MethodGet, path: "/admin/stores", wantStatus: http.StatusUnauthorized},
+		{name: "missing group role", method: http.MethodGet, path: "/admin/stores", subject: "u1", scopes: "stores:read", wantStatus: http.StatusForbidden},
+		{name: "missing route scope", method: http.MethodGet, path: "/admin/stores", subject: "u1", roles: "admin", wantStatus: http.StatusForbidden},
+		{name: "role and scope", method: http.MethodGet, path: "/admin/stores", subject: "u1", scopes: "stores:read", roles: "support", wantStatus: http.StatusOK},
+		{name: "policy passes", method: http.MethodDelete, path: "/admin/stores/u1", subject: "u1", roles: "admin", wantStatus: http.StatusOK},
+		{name: "policy fails", method: http.MethodDelete, path: "/admin/stores/u2", subject: "u1", roles: "admin", wantStatus: http.StatusForbidden},
+		{name: "route authentication", method: http.MethodGet, path: "/admin/me", subject: "u1", roles: "admin", wantStatus: http.StatusOK},
+		{name: "unrestricted sibling", method: http.MethodGet, path: "/admin/health", wantStatus: http.StatusOK},
+	}
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	ok := func(c Context) error { return c.NoContent(http.StatusOK) }
+
+	srv.Group("admin", func(g *Group) {
+		g.GET("/health", ok, fakeAuth)
+
+		admin := g.Require(RequireRoles("admin", "support"))
+		admin.Require(RequireScopes("stores:read")).GET("/stores", ok, fakeAuth)
+		admin.Require(RequirePolicy("owns-store", ownsStore)).DELETE("/stores/:id", ok, fakeAuth)
+		admin.GET("/me", ok, fakeAuth)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Subject", tt.subject)
+			req.Header.Set("X-Scopes", tt.scopes)
+			req.Header.Set("X-Roles", tt.roles)
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	want := map[string]string{
+		"GET /admin/health":        "GET /admin/health",
+		"GET /admin/stores":        "GET /admin/stores [roles: admin | support; scopes: stores:read]",
+		"DELETE /admin/stores/:id": "DELETE /admin/stores/:id [roles: admin | support; policy: owns-store]",
+		"GET /admin/me":            "GET /admin/me [roles: admin | support]",
+	}
+
+	routes := srv.Routes()
+	if len(routes) != len(want) {
+		t.Fatalf("Routes() = %v, want %d routes", routes, len(want))
+	}
+
+	for _, r := range routes {
+		if got := r.String(); got != want[r.Method+" "+r.Path] {
+			t.Errorf("route %s %s = %q, want %q", r.Method, r.Path, got, want[r.Method+" "+r.Path])
+		}
+	}
+}
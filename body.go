@@ -0,0 +1,231 @@
+package echoext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// bodyLimitKey holds the request's *bodyLimit, set to the MaxBytes of
+	// the innermost Body middleware.
+	bodyLimitKey = "echoext.body.limit"
+	// strictJSONKey marks requests whose JSON bodies Bind and BindValidate
+	// decode strictly.
+	strictJSONKey = "echoext.body.strict_json"
+	// contentTypesKey holds the allowed content types of the innermost Body
+	// middleware, checked by enforceBody just before the handler.
+	contentTypesKey = "echoext.body.content_types"
+)
+
+var (
+	errBodyTooLarge         = newHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+	errUnsupportedMediaType = newHTTPError(http.StatusUnsupportedMediaType, "unsupported media type")
+)
+
+// BodyConfig configures request body enforcement. On ServerConfig it applies
+// to every route; pass it to Body to override it on a group or route.
+type BodyConfig struct {
+	// MaxBytes limits the request body size. Zero leaves the outer limit in
+	// place (none at the server level).
+	MaxBytes int64
+	// StrictJSON makes Bind and BindValidate reject unknown JSON fields and
+	// trailing data after the JSON value.
+	StrictJSON bool
+	// AllowedContentTypes restricts the Content-Type of requests carrying a
+	// body. Entries may use a wildcard subtype such as "image/*". Empty
+	// allows any type.
+	AllowedContentTypes []string
+}
+
+func (c *BodyConfig) enabled() bool {
+	return c.MaxBytes > 0 || c.StrictJSON || len(c.AllowedContentTypes) > 0
+}
+
+// Body returns a middleware enforcing cfg. Oversized bodies fail with 413 and
+// disallowed content types with 415. The innermost MaxBytes and
+// AllowedContentTypes win, so a route can relax its group's or the server's
+// rules as well as tighten them. Content-Length and content types are
+// checked right before the handler of routes registered through Group;
+// reads are limited wherever they happen.
+func Body(cfg BodyConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			if len(cfg.AllowedContentTypes) > 0 {
+				c.Set(contentTypesKey, cfg.AllowedContentTypes)
+			}
+
+			if cfg.StrictJSON {
+				c.Set(strictJSONKey, true)
+			}
+
+			if cfg.MaxBytes > 0 {
+				limit, ok := c.Get(bodyLimitKey).(*bodyLimit)
+				if !ok {
+					limit = &bodyLimit{}
+					c.Set(bodyLimitKey, limit)
+				}
+
+				limit.max.Store(cfg.MaxBytes)
+
+				// Middleware between two Body layers may have replaced the
+				// body, so the current one is wrapped.
+				if req.Body != nil && req.Body != http.NoBody {
+					req.Body = &limitedBody{ReadCloser: req.Body, limit: limit}
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// bodyLimit is the effective body size limit of a request. Every Body layer
+// updates it, so readers wrapped by outer layers apply the innermost limit.
+type bodyLimit struct {
+	max atomic.Int64
+}
+
+// limitedBody fails reads past the request's current body limit with
+// *http.MaxBytesError.
+type limitedBody struct {
+	io.ReadCloser
+	limit *bodyLimit
+	n     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	limit := b.limit.max.Load()
+	if b.n > limit {
+		return 0, &http.MaxBytesError{Limit: limit}
+	}
+
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a larger one.
+	if rest := limit - b.n + 1; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+
+	if b.n > limit {
+		return n - int(b.n-limit), &http.MaxBytesError{Limit: limit}
+	}
+
+	return n, err
+}
+
+// BodyLimit returns a middleware limiting the request body to n bytes.
+func BodyLimit(n int64) MiddlewareFunc {
+	return Body(BodyConfig{MaxBytes: n})
+}
+
+// AllowContentTypes returns a middleware rejecting request bodies whose
+// Content-Type is not one of types.
+func AllowContentTypes(types ...string) MiddlewareFunc {
+	return Body(BodyConfig{AllowedContentTypes: types})
+}
+
+// enforceBody rejects the request when its Content-Length exceeds the
+// effective body limit or its body's Content-Type is not allowed by the
+// innermost Body middleware.
+func enforceBody(h HandlerFunc) HandlerFunc {
+	return func(c Context) error {
+		req := c.Request()
+		if limit, ok := c.Get(bodyLimitKey).(*bodyLimit); ok && req.ContentLength > limit.max.Load() {
+			return errBodyTooLarge
+		}
+
+		allowed, _ := c.Get(contentTypesKey).([]string)
+		if len(allowed) > 0 && hasBody(req) && !contentTypeAllowed(req.Header.Get(echo.HeaderContentType), allowed) {
+			return errUnsupportedMediaType
+		}
+
+		return h(c)
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	// ContentLength is -1 for chunked and decompressed bodies.
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0
+}
+
+// contentTypeAllowed reports whether the media type of header matches one of
+// allowed, ignoring parameters such as charset.
+func contentTypeAllowed(header string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == mediaType || a == "*/*" {
+			return true
+		}
+
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bindStrict binds like echo.DefaultBinder but decodes JSON bodies rejecting
+// unknown fields and trailing data.
+func bindStrict(c echo.Context, i interface{}) error {
+	b := &echo.DefaultBinder{}
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+
+	req := c.Request()
+	if req.Method == http.MethodGet || req.Method == http.MethodDelete || req.Method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if req.ContentLength == 0 || mediaType != echo.MIMEApplicationJSON {
+		return b.BindBody(c, i)
+	}
+
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(i); err != nil {
+		return newHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after JSON body")
+		}
+
+		return newHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err)).SetInternal(err)
+	}
+
+	return nil
+}
+
+// bodyError maps body read failures caused by a size limit to 413.
+func bodyError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return errBodyTooLarge
+	}
+
+	return err
+}
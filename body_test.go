@@ -0,0 +1,145 @@
+package echoext
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// replaceBody reads the body and replaces it, as VerifyWebhook and
+// Idempotency do.
+func replaceBody(next HandlerFunc) HandlerFunc {
+	return func(c Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return bodyError(err)
+		}
+
+		c.Request().Body = io.NopCloser(bytes.NewReader(b))
+
+		return next(c)
+	}
+}
+
+func TestBodyLimitNesting(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     int64
+		group      []MiddlewareFunc
+		route      []MiddlewareFunc
+		size       int
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within server limit", server: 100, size: 50, wantStatus: http.StatusOK},
+		{name: "over server limit", server: 10, size: 50, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "route raises limit", server: 10, route: []MiddlewareFunc{BodyLimit(100)}, size: 50, wantStatus: http.StatusOK},
+		{name: "route raises limit chunked", server: 10, route: []MiddlewareFunc{BodyLimit(100)}, size: 50, chunked: true, wantStatus: http.StatusOK},
+		{name: "group raises limit", server: 10, group: []MiddlewareFunc{BodyLimit(100)}, size: 50, wantStatus: http.StatusOK},
+		{name: "route lowers group limit", group: []MiddlewareFunc{BodyLimit(100)}, route: []MiddlewareFunc{BodyLimit(10)}, size: 50, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "route lowers limit chunked", server: 100, route: []MiddlewareFunc{BodyLimit(10)}, size: 50, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "body replaced between layers", server: 100, route: []MiddlewareFunc{replaceBody, BodyLimit(200)}, size: 50, wantStatus: http.StatusOK},
+		{name: "replaced body over inner limit", server: 100, route: []MiddlewareFunc{replaceBody, BodyLimit(10)}, size: 50, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "exactly the limit", server: 50, size: 50, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{
+				Environment:   Production,
+				BodyConfig:    BodyConfig{MaxBytes: tt.server},
+				MetricsConfig: MetricsConfig{Disabled: true},
+			})
+
+			srv.Group("upload", func(g *Group) {
+				g.POST("", func(c Context) error {
+					// The error handler maps the raw read error to 413.
+					b, err := io.ReadAll(c.Request().Body)
+					if err != nil {
+						return err
+					}
+
+					return c.String(http.StatusOK, strconv.Itoa(len(b)))
+				}, tt.route...)
+			}, tt.group...)
+
+			body := strings.Repeat("a", tt.size)
+			req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != strconv.Itoa(tt.size) {
+				t.Fatalf("handler read %s bytes, want %d", rec.Body, tt.size)
+			}
+		})
+	}
+}
+
+func TestStrictJSONBinding(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		strict     bool
+		validate   bool
+		wantStatus int
+	}{
+		{name: "lenient unknown field", body: `{"name":"a","extra":1}`, wantStatus: http.StatusOK},
+		{name: "bind unknown field", body: `{"name":"a","extra":1}`, strict: true, wantStatus: http.StatusBadRequest},
+		{name: "bind trailing data", body: `{"name":"a"}{}`, strict: true, wantStatus: http.StatusBadRequest},
+		{name: "bind known fields", body: `{"name":"a"}`, strict: true, wantStatus: http.StatusOK},
+		{name: "bind validate unknown field", body: `{"name":"a","extra":1}`, strict: true, validate: true, wantStatus: http.StatusBadRequest},
+		{name: "bind validate known fields", body: `{"name":"a"}`, strict: true, validate: true, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{
+				Environment:   Production,
+				BodyConfig:    BodyConfig{StrictJSON: tt.strict},
+				MetricsConfig: MetricsConfig{Disabled: true},
+			})
+
+			srv.Group("items", func(g *Group) {
+				g.POST("", func(c Context) error {
+					var item struct {
+						Name string `json:"name" validate:"required"`
+					}
+
+					bind := c.Bind
+					if tt.validate {
+						bind = c.BindValidate
+					}
+
+					if err := bind(&item); err != nil {
+						return err
+					}
+
+					return c.String(http.StatusOK, item.Name)
+				})
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
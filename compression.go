@@ -0,0 +1,397 @@
+package echoext
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+var errInvalidGzipBody = newHTTPError(http.StatusBadRequest, "invalid gzip request body")
+
+// CompressionConfig configures response compression and request
+// decompression.
+type CompressionConfig struct {
+	// Disabled removes compression from the server's default stack.
+	Disabled bool
+	// Encodings are the supported response encodings in order of preference,
+	// among "br", "zstd", "gzip" and "deflate". Defaults to all of them in
+	// that order.
+	Encodings []string
+	// MinSize is the smallest response body, in bytes, that is compressed.
+	// Defaults to 1024.
+	MinSize int
+	// ContentTypes are the compressible response types. Entries may use a
+	// wildcard subtype such as "text/*". Defaults to text, JSON, JavaScript,
+	// XML and SVG.
+	ContentTypes []string
+	// DisableDecompression leaves gzip-encoded request bodies untouched.
+	DisableDecompression bool
+	// MaxDecompressedBytes caps decompressed request bodies when no smaller
+	// body limit applies. Defaults to 10 MiB.
+	MaxDecompressedBytes int64
+}
+
+func (c CompressionConfig) withDefaults() CompressionConfig {
+	if len(c.Encodings) == 0 {
+		c.Encodings = []string{"br", "zstd", "gzip", "deflate"}
+	}
+
+	if c.MinSize <= 0 {
+		c.MinSize = 1024
+	}
+
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = []string{
+			"text/*",
+			echo.MIMEApplicationJSON,
+			"application/problem+json",
+			"application/javascript",
+			echo.MIMEApplicationXML,
+			"image/svg+xml",
+		}
+	}
+
+	if c.MaxDecompressedBytes <= 0 {
+		c.MaxDecompressedBytes = 10 << 20
+	}
+
+	return c
+}
+
+// compressor is implemented by the pooled encoders.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressors pools encoders by Content-Encoding. Brotli runs at level 4,
+// which trades some ratio for the speed dynamic responses need.
+var compressors = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, 4)
+	}},
+	"zstd": {New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	"deflate": {New: func() any {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	}},
+}
+
+// Compression returns a middleware compressing responses with the encoding
+// negotiated from Accept-Encoding and decompressing gzip request bodies.
+// Responses are compressed only when their Content-Type is allowed and their
+// body reaches MinSize. Server-Sent Events and responses flushed before
+// reaching MinSize are sent uncompressed, so streaming keeps working. Strong
+// ETags of compressed responses get the encoding appended.
+// Decompressed request bodies count against the innermost body limit, which
+// guards against zip bombs. It panics on an unknown encoding.
+func Compression(cfg CompressionConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	for _, enc := range cfg.Encodings {
+		if compressors[enc] == nil {
+			panic("echoext: unsupported compression encoding " + strconv.Quote(enc))
+		}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			if !cfg.DisableDecompression {
+				if err := decompressBody(c, cfg.MaxDecompressedBytes); err != nil {
+					return err
+				}
+			}
+
+			if req.Header.Get(echo.HeaderUpgrade) != "" {
+				return next(c)
+			}
+
+			res := c.Response()
+			if !slices.Contains(res.Header().Values(echo.HeaderVary), echo.HeaderAcceptEncoding) {
+				res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			}
+
+			encoding := negotiateEncoding(req.Header.Get(echo.HeaderAcceptEncoding), cfg.Encodings)
+			if encoding == "" || req.Method == http.MethodHead {
+				return next(c)
+			}
+
+			cw := &compressWriter{ResponseWriter: res.Writer, encoding: encoding, cfg: &cfg}
+			res.Writer = cw
+			defer func() {
+				cw.close()
+				res.Writer = cw.ResponseWriter
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// decompressBody replaces a gzip-encoded request body with its decompressed
+// form. When a body limit is already in place, it also applies to the
+// decompressed bytes.
+func decompressBody(c Context, maxBytes int64) error {
+	req := c.Request()
+
+	switch strings.ToLower(strings.TrimSpace(req.Header.Get(echo.HeaderContentEncoding))) {
+	case "gzip", "x-gzip":
+	default:
+		return nil
+	}
+
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return errBodyTooLarge
+		}
+
+		return errInvalidGzipBody
+	}
+
+	body := http.MaxBytesReader(c.Response(), zr, maxBytes)
+	if limit, ok := c.Get(bodyLimitKey).(*bodyLimit); ok {
+		body = &limitedBody{ReadCloser: body, limit: limit}
+	}
+
+	req.Body = body
+	req.ContentLength = -1
+	req.Header.Del(echo.HeaderContentEncoding)
+	req.Header.Del(echo.HeaderContentLength)
+
+	return nil
+}
+
+// negotiateEncoding picks the supported encoding with the highest quality in
+// the Accept-Encoding header, preferring earlier entries of supported on
+// ties. It returns "" when none is acceptable.
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+type compressMode int
+
+const (
+	// compressPending waits for the status code.
+	compressPending compressMode = iota
+	// compressBuffering holds the body until it reaches MinSize.
+	compressBuffering
+	compressActive
+	compressPassthrough
+)
+
+// compressWriter decides on the first write whether to compress the response
+// and then either encodes or passes it through.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	cfg      *CompressionConfig
+	mode     compressMode
+	status   int
+	buf      []byte
+	enc      compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	switch {
+	case w.mode == compressPassthrough, code < http.StatusOK:
+		// Informational responses such as 103 Early Hints precede the
+		// final one.
+		w.ResponseWriter.WriteHeader(code)
+	case w.mode == compressPending:
+		w.status = code
+		if code == http.StatusNotModified {
+			// The client validated the representation in this encoding.
+			w.encodeETag()
+		}
+
+		if w.compressible() {
+			w.mode = compressBuffering
+			return
+		}
+
+		w.mode = compressPassthrough
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.mode == compressPending {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch w.mode {
+	case compressActive:
+		return w.enc.Write(b)
+	case compressBuffering:
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.cfg.MinSize {
+			if err := w.start(); err != nil {
+				return 0, err
+			}
+		}
+
+		return len(b), nil
+	default:
+		return w.ResponseWriter.Write(b)
+	}
+}
+
+// Flush sends a still-buffered response uncompressed, since a stream that
+// flushes early gains little from compression, and flushes the encoder of a
+// compressed one.
+func (w *compressWriter) Flush() {
+	switch w.mode {
+	case compressPending:
+		w.mode = compressPassthrough
+	case compressBuffering:
+		w.passBuffered()
+	case compressActive:
+		_ = w.enc.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response headers allow compression.
+func (w *compressWriter) compressible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+
+	h := w.Header()
+	if h.Get(echo.HeaderContentEncoding) != "" {
+		return false
+	}
+
+	if n, err := strconv.Atoi(h.Get(echo.HeaderContentLength)); err == nil && n < w.cfg.MinSize {
+		return false
+	}
+
+	ct := h.Get(echo.HeaderContentType)
+	if mediaType, _, _ := mime.ParseMediaType(ct); mediaType == "text/event-stream" {
+		return false
+	}
+
+	return contentTypeAllowed(ct, w.cfg.ContentTypes)
+}
+
+// start writes the header and the buffered body through a pooled encoder.
+func (w *compressWriter) start() error {
+	h := w.Header()
+	h.Set(echo.HeaderContentEncoding, w.encoding)
+	h.Del(echo.HeaderContentLength)
+	w.encodeETag()
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.enc = compressors[w.encoding].Get().(compressor)
+	w.enc.Reset(w.ResponseWriter)
+	w.mode = compressActive
+
+	_, err := w.enc.Write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+// encodeETag appends the encoding to a strong ETag, which names the
+// uncompressed bytes, since strong validators must differ per encoding.
+// etagListMatches strips the suffix again, so If-Match and If-None-Match
+// keep matching the handler's ETag.
+func (w *compressWriter) encodeETag() {
+	h := w.Header()
+	if etag := h.Get("ETag"); strings.HasSuffix(etag, `"`) && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+w.encoding+`"`)
+	}
+}
+
+// decodedETag returns etag without the encoding suffix encodeETag appends.
+func decodedETag(etag string) string {
+	for enc := range compressors {
+		if tag, ok := strings.CutSuffix(etag, "-"+enc+`"`); ok {
+			return tag + `"`
+		}
+	}
+
+	return etag
+}
+
+// passBuffered sends the buffered response uncompressed.
+func (w *compressWriter) passBuffered() {
+	w.mode = compressPassthrough
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf)
+	}
+
+	w.buf = nil
+}
+
+// close completes the response once the handler returns.
+func (w *compressWriter) close() {
+	switch w.mode {
+	case compressBuffering:
+		w.passBuffered()
+	case compressActive:
+		_ = w.enc.Close()
+		w.enc.Reset(io.Discard)
+		compressors[w.encoding].Put(w.enc)
+		w.enc = nil
+		w.mode = compressPassthrough
+	}
+}
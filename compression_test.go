@@ -0,0 +1,118 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionEncodesStrongETag(t *testing.T) {
+	body := strings.Repeat(`{"item":"menu"}`, 200)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		etag           string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{name: "gzip strong", acceptEncoding: "gzip", etag: `"abc"`, wantEncoding: "gzip", wantETag: `"abc-gzip"`},
+		{name: "br strong", acceptEncoding: "br", etag: `"abc"`, wantEncoding: "br", wantETag: `"abc-br"`},
+		{name: "zstd strong", acceptEncoding: "zstd", etag: `"abc"`, wantEncoding: "zstd", wantETag: `"abc-zstd"`},
+		{name: "weak kept", acceptEncoding: "gzip", etag: `W/"abc"`, wantEncoding: "gzip", wantETag: `W/"abc"`},
+		{name: "identity strong kept", etag: `"abc"`, wantETag: `"abc"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("menu", func(g *Group) {
+				g.GET("", func(c Context) error {
+					c.Response().Header().Set("ETag", tt.etag)
+					return c.Blob(http.StatusOK, "application/json", []byte(body))
+				})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("ETag = %q, want %q", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestCompressedETagPreconditions(t *testing.T) {
+	menu := map[string]string{"items": strings.Repeat("menu ", 400)}
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("menu", func(g *Group) {
+		g.GET("", func(c Context) error { return c.JSON(http.StatusOK, menu) })
+		g.PUT("", func(c Context) error {
+			current, err := JSONETag(c, menu)
+			if err != nil {
+				return err
+			}
+
+			if err := c.IfMatch(current); err != nil {
+				return err
+			}
+
+			return c.NoContent(http.StatusNoContent)
+		})
+	}, HTTPCache(HTTPCacheConfig{}))
+
+	get := httptest.NewRequest(http.MethodGet, "/menu", nil)
+	get.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(rec, get)
+
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Content-Encoding") != "gzip" || !strings.HasSuffix(etag, `-gzip"`) {
+		t.Fatalf("GET: Content-Encoding %q, ETag %q", rec.Header().Get("Content-Encoding"), etag)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "if-match echoed tag", method: http.MethodPut, header: "If-Match", value: etag, want: http.StatusNoContent},
+		{name: "if-match stale tag", method: http.MethodPut, header: "If-Match", value: `"stale-gzip"`, want: http.StatusPreconditionFailed},
+		{name: "if-match weakened tag", method: http.MethodPut, header: "If-Match", value: "W/" + etag, want: http.StatusPreconditionFailed},
+		{name: "if-none-match echoed tag", method: http.MethodGet, header: "If-None-Match", value: etag, want: http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/menu", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			req.Header.Set(tt.header, tt.value)
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("%s with %s %s = %d, want %d", tt.method, tt.header, tt.value, rec.Code, tt.want)
+			}
+
+			if tt.want == http.StatusNotModified && rec.Header().Get("ETag") != etag {
+				t.Fatalf("304 ETag = %q, want %q", rec.Header().Get("ETag"), etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package echoext
+
+import (
+	"container/heap"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConcurrencyMode selects how a concurrency limiter sizes its limit.
+type ConcurrencyMode string
+
+const (
+	// FixedConcurrency keeps the limit at ConcurrencyLimitConfig.Limit.
+	FixedConcurrency ConcurrencyMode = "fixed"
+	// AIMDConcurrency grows the limit by one per limit's worth of fast
+	// requests and cuts it by Backoff when a request exceeds TargetLatency.
+	// It cuts at most once per round trip: slow requests that started before
+	// the last cut do not cut again.
+	AIMDConcurrency ConcurrencyMode = "aimd"
+	// GradientConcurrency scales the limit by the ratio between long-term and
+	// current latency, shrinking it as queueing delay builds up.
+	GradientConcurrency ConcurrencyMode = "gradient"
+)
+
+// ConcurrencyLimitConfig configures the concurrency limiting middleware. The
+// global limiter on ServerConfig is enabled when Limit is greater than zero.
+type ConcurrencyLimitConfig struct {
+	// Name labels the limiter's metrics. Unnamed limiters are named
+	// "default", "default_2" and so on in creation order, so set it when
+	// several limiters are used.
+	Name string
+	// Mode defaults to FixedConcurrency.
+	Mode ConcurrencyMode
+	// Limit is the number of requests served concurrently. Adaptive modes
+	// use it as the starting point.
+	Limit int
+	// MinLimit and MaxLimit bound adaptive modes. They default to 1 and to
+	// ten times Limit.
+	MinLimit int
+	MaxLimit int
+	// QueueSize is how many requests may wait for a slot. Zero sheds as soon
+	// as the limit is reached.
+	QueueSize int
+	// QueueTimeout bounds how long a queued request waits. Defaults to one
+	// second.
+	QueueTimeout time.Duration
+	// Priority orders queued requests; higher values are served first and
+	// ties are served in arrival order. Defaults to every request being
+	// equal.
+	Priority func(c Context) int
+	// TargetLatency is the latency above which AIMDConcurrency backs off.
+	// Defaults to 500ms.
+	TargetLatency time.Duration
+	// Backoff is the multiplicative decrease applied by AIMDConcurrency,
+	// at most once per round trip. Defaults to 0.9.
+	Backoff float64
+	// RetryAfter is advertised to shed clients. Defaults to one second.
+	RetryAfter time.Duration
+}
+
+func (c *ConcurrencyLimitConfig) enabled() bool {
+	return c.Limit > 0
+}
+
+func (c ConcurrencyLimitConfig) withDefaults() ConcurrencyLimitConfig {
+	if c.Name == "" {
+		c.Name = defaultLimiterName("concurrency")
+	}
+
+	if c.Mode == "" {
+		c.Mode = FixedConcurrency
+	}
+
+	if c.MinLimit <= 0 {
+		c.MinLimit = 1
+	}
+
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = 10 * c.Limit
+	}
+
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = time.Second
+	}
+
+	if c.TargetLatency <= 0 {
+		c.TargetLatency = 500 * time.Millisecond
+	}
+
+	if c.Backoff <= 0 || c.Backoff >= 1 {
+		c.Backoff = 0.9
+	}
+
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = time.Second
+	}
+
+	return c
+}
+
+// ConcurrencyLimit returns a middleware that bounds the number of requests
+// served at once. Requests over the limit wait in a priority queue; when the
+// queue is full or the wait exceeds QueueTimeout they are shed with 503 and
+// Retry-After, and counted in http_requests_shed_total.
+func ConcurrencyLimit(cfg ConcurrencyLimitConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	l := newConcurrencyLimiter(cfg)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			if cfg.Limit <= 0 {
+				return next(c)
+			}
+
+			priority := 0
+			if cfg.Priority != nil {
+				priority = cfg.Priority(c)
+			}
+
+			if reason := l.acquire(c.Request().Context().Done(), priority); reason != "" {
+				httpRequestsShed.WithLabelValues(cfg.Name, reason).Inc()
+				c.Response().Header().Set("Retry-After", strconv.Itoa(ceilSeconds(cfg.RetryAfter)))
+				return newHTTPError(http.StatusServiceUnavailable, "server is overloaded")
+			}
+
+			start := time.Now()
+			defer func() { l.release(start) }()
+
+			return next(c)
+		}
+	}
+}
+
+// concurrencyLimiter tracks in-flight requests against an optionally adaptive
+// limit and hands freed slots to queued waiters by priority.
+type concurrencyLimiter struct {
+	cfg ConcurrencyLimitConfig
+
+	mu          sync.Mutex
+	limit       float64
+	inflight    int
+	queue       waitQueue
+	seq         uint64
+	longLatency float64
+	// backedOff is when AIMDConcurrency last cut the limit.
+	backedOff time.Time
+}
+
+// limiterNames counts the unnamed limiters of each kind.
+var limiterNames = struct {
+	mu sync.Mutex
+	n  map[string]int
+}{n: map[string]int{}}
+
+// defaultLimiterName returns a name for an unnamed limiter of kind, unique
+// within the process so limiters never share metric labels or store keys.
+func defaultLimiterName(kind string) string {
+	limiterNames.mu.Lock()
+	defer limiterNames.mu.Unlock()
+
+	limiterNames.n[kind]++
+	if n := limiterNames.n[kind]; n > 1 {
+		return "default_" + strconv.Itoa(n)
+	}
+
+	return "default"
+}
+
+func newConcurrencyLimiter(cfg ConcurrencyLimitConfig) *concurrencyLimiter {
+	l := &concurrencyLimiter{cfg: cfg, limit: float64(cfg.Limit)}
+	concurrencyLimitGauge.WithLabelValues(cfg.Name).Set(l.limit)
+
+	return l
+}
+
+// acquire reserves a slot, queueing when none is free. It returns the shed
+// reason, or an empty string once the caller holds a slot.
+func (l *concurrencyLimiter) acquire(done <-chan struct{}, priority int) string {
+	l.mu.Lock()
+	if l.inflight < int(l.limit) && l.queue.Len() == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return ""
+	}
+
+	if l.queue.Len() >= l.cfg.QueueSize {
+		l.mu.Unlock()
+		return "queue_full"
+	}
+
+	l.seq++
+	w := &waiter{priority: priority, seq: l.seq, ready: make(chan struct{})}
+	heap.Push(&l.queue, w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	reason := ""
+	select {
+	case <-w.ready:
+		return ""
+	case <-timer.C:
+		reason = "queue_timeout"
+	case <-done:
+		reason = "canceled"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The slot may have been granted while we were timing out.
+	if w.index < 0 {
+		return ""
+	}
+
+	heap.Remove(&l.queue, w.index)
+
+	return reason
+}
+
+// release frees the slot of a request that started at start, feeds its
+// latency into the adaptive limit and wakes as many waiters as the limit
+// allows.
+func (l *concurrencyLimiter) release(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	l.adapt(start, time.Now())
+
+	for l.inflight < int(l.limit) && l.queue.Len() > 0 {
+		w := heap.Pop(&l.queue).(*waiter)
+		l.inflight++
+		close(w.ready)
+	}
+}
+
+// adapt updates the limit from a request that ran from start to end. Callers
+// must hold l.mu.
+func (l *concurrencyLimiter) adapt(start, end time.Time) {
+	latency := end.Sub(start)
+	sample := latency.Seconds()
+
+	switch l.cfg.Mode {
+	case AIMDConcurrency:
+		if latency > l.cfg.TargetLatency {
+			// Requests in flight at the last cut saw the same overload; only
+			// one that started after it shows the cut was not enough.
+			if !start.After(l.backedOff) {
+				return
+			}
+
+			l.limit *= l.cfg.Backoff
+			l.backedOff = end
+		} else {
+			l.limit += 1 / l.limit
+		}
+	case GradientConcurrency:
+		if l.longLatency == 0 {
+			l.longLatency = sample
+		}
+		l.longLatency = 0.95*l.longLatency + 0.05*sample
+
+		gradient := 1.0
+		if sample > 0 {
+			gradient = math.Max(0.5, math.Min(1, l.longLatency/sample))
+		}
+
+		// The square root term leaves headroom for a small queue so the
+		// limit can grow back once latency recovers.
+		target := l.limit*gradient + math.Sqrt(l.limit)
+		l.limit = 0.8*l.limit + 0.2*target
+	default:
+		return
+	}
+
+	l.limit = math.Max(float64(l.cfg.MinLimit), math.Min(float64(l.cfg.MaxLimit), l.limit))
+	concurrencyLimitGauge.WithLabelValues(l.cfg.Name).Set(math.Floor(l.limit))
+}
+
+// waiter is a request queued for a slot. index is -1 once it has left the
+// queue.
+type waiter struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+}
+
+// waitQueue is a heap of waiters ordered by priority, then arrival.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+
+	return w
+}
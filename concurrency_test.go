@@ -0,0 +1,222 @@
+package echoext
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerLimiterName(t *testing.T) {
+	tests := []struct {
+		configured, server, want string
+	}{
+		{configured: "", server: "main", want: "main"},
+		{configured: "", server: "internal", want: "internal"},
+		{configured: "api", server: "main", want: "api"},
+		{configured: "api", server: "internal", want: "api_internal"},
+	}
+
+	for _, tt := range tests {
+		if got := serverLimiterName(tt.configured, tt.server); got != tt.want {
+			t.Errorf("serverLimiterName(%q, %q) = %q, want %q", tt.configured, tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultLimiterNamesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for range 3 {
+		name := defaultLimiterName("test")
+		if seen[name] {
+			t.Fatalf("default limiter name %q reused", name)
+		}
+
+		seen[name] = true
+	}
+}
+
+// queued reports whether n requests wait in l's queue.
+func queued(l *concurrencyLimiter, n int) func() bool {
+	return func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		return l.queue.Len() == n
+	}
+}
+
+func TestConcurrencyLimiterQueues(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimitConfig{Name: "test_queue", Limit: 1, QueueSize: 1}.withDefaults())
+	if reason := l.acquire(nil, 0); reason != "" {
+		t.Fatalf("first acquire shed: %s", reason)
+	}
+
+	got := make(chan string, 1)
+	go func() { got <- l.acquire(nil, 0) }()
+	waitFor(t, queued(l, 1))
+
+	select {
+	case reason := <-got:
+		t.Fatalf("queued request returned %q while the slot was held", reason)
+	default:
+	}
+
+	l.release(time.Now())
+	if reason := <-got; reason != "" {
+		t.Fatalf("queued request shed after release: %s", reason)
+	}
+}
+
+func TestConcurrencyLimiterPriority(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimitConfig{Name: "test_priority", Limit: 1, QueueSize: 3, QueueTimeout: 5 * time.Second}.withDefaults())
+	if reason := l.acquire(nil, 0); reason != "" {
+		t.Fatalf("first acquire shed: %s", reason)
+	}
+
+	served := make(chan int, 3)
+	for i, priority := range []int{0, 5, 1} {
+		go func() {
+			if reason := l.acquire(nil, priority); reason == "" {
+				served <- priority
+			}
+		}()
+		waitFor(t, queued(l, i+1))
+	}
+
+	for _, want := range []int{5, 1, 0} {
+		l.release(time.Now())
+		if got := <-served; got != want {
+			t.Fatalf("served priority %d, want %d", got, want)
+		}
+	}
+}
+
+func TestConcurrencyLimiterSheds(t *testing.T) {
+	canceled := make(chan struct{})
+	close(canceled)
+
+	tests := []struct {
+		name string
+		cfg  ConcurrencyLimitConfig
+		done <-chan struct{}
+		want string
+	}{
+		{name: "no queue", cfg: ConcurrencyLimitConfig{Limit: 1}, want: "queue_full"},
+		{name: "queue timeout", cfg: ConcurrencyLimitConfig{Limit: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond}, want: "queue_timeout"},
+		{name: "canceled", cfg: ConcurrencyLimitConfig{Limit: 1, QueueSize: 1, QueueTimeout: time.Minute}, done: canceled, want: "canceled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Name = "test_shed"
+			l := newConcurrencyLimiter(tt.cfg.withDefaults())
+			if reason := l.acquire(nil, 0); reason != "" {
+				t.Fatalf("first acquire shed: %s", reason)
+			}
+
+			if got := l.acquire(tt.done, 0); got != tt.want {
+				t.Fatalf("acquire() = %q, want %q", got, tt.want)
+			}
+
+			if !queued(l, 0)() || l.inflight != 1 {
+				t.Fatalf("after shedding: %d queued, %d in flight", l.queue.Len(), l.inflight)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitResponds503(t *testing.T) {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("work", func(g *Group) {
+		g.GET("", func(c Context) error {
+			entered <- struct{}{}
+			<-unblock
+
+			return c.NoContent(http.StatusNoContent)
+		}, ConcurrencyLimit(ConcurrencyLimitConfig{Name: "test_503", Limit: 1, RetryAfter: 2 * time.Second}))
+	})
+
+	first := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+		first <- rec.Code
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("shed request = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	close(unblock)
+	if code := <-first; code != http.StatusNoContent {
+		t.Fatalf("admitted request = %d", code)
+	}
+}
+
+func TestAIMDConcurrencyBacksOffOncePerRoundTrip(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimitConfig{
+		Name:          "test_aimd",
+		Mode:          AIMDConcurrency,
+		Limit:         10,
+		TargetLatency: 100 * time.Millisecond,
+		Backoff:       0.5,
+	}.withDefaults())
+
+	t0 := time.Now()
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+
+	steps := []struct {
+		name       string
+		start, end time.Time
+		want       float64
+	}{
+		{name: "slow request cuts", start: at(0), end: at(time.Second), want: 5},
+		{name: "slow request from the same burst", start: at(0), end: at(1500 * time.Millisecond), want: 5},
+		{name: "slow request started before the cut", start: at(500 * time.Millisecond), end: at(2 * time.Second), want: 5},
+		{name: "slow request started after the cut", start: at(1500 * time.Millisecond), end: at(2500 * time.Millisecond), want: 2.5},
+		{name: "fast request grows", start: at(3 * time.Second), end: at(3010 * time.Millisecond), want: 2.9},
+		{name: "next cut", start: at(4 * time.Second), end: at(5 * time.Second), want: 1.45},
+		{name: "bounded by MinLimit", start: at(6 * time.Second), end: at(7 * time.Second), want: 1},
+	}
+
+	for _, s := range steps {
+		l.adapt(s.start, s.end)
+		if math.Abs(l.limit-s.want) > 1e-9 {
+			t.Fatalf("%s: limit = %g, want %g", s.name, l.limit, s.want)
+		}
+	}
+}
+
+func TestGradientConcurrency(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimitConfig{Name: "test_gradient", Mode: GradientConcurrency, Limit: 10, MaxLimit: 20}.withDefaults())
+
+	run := func(latency time.Duration, n int) float64 {
+		start := time.Now()
+		for range n {
+			l.adapt(start, start.Add(latency))
+		}
+
+		return l.limit
+	}
+
+	steady := run(100*time.Millisecond, 10)
+	if steady <= 10 {
+		t.Fatalf("limit under steady latency = %g, want it to grow past 10", steady)
+	}
+
+	if got := run(100*time.Millisecond, 100); got != 20 {
+		t.Fatalf("limit = %g, want it capped at MaxLimit 20", got)
+	}
+
+	if got := run(time.Second, 10); got >= 20 {
+		t.Fatalf("limit under rising latency = %g, want it to shrink below 20", got)
+	}
+}
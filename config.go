@@ -1,20 +1,43 @@
 package echoext
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 )
 
 type ServerConfig struct {
-	PathPrefix       string
-	Host             string
-	Port             int
-	HealthcheckPath  string
-	SkipPaths        []string
-	SwaggerConfig    SwaggerConfig
-	ExtraCORSHeaders []string
-	Mode             EchoMode
-	MetricsConfig    MetricsConfig
+	PathPrefix             string
+	Host                   string
+	Port                   int `validate:"gte=0,lte=65535"`
+	HealthcheckPath        string
+	SkipPaths              []string
+	SwaggerConfig          SwaggerConfig `config:"swagger"`
+	ExtraCORSHeaders       []string
+	Mode                   EchoMode
+	MetricsConfig          MetricsConfig          `config:"metrics"`
+	RateLimitConfig        RateLimitConfig        `config:"rate_limit"`
+	ConcurrencyLimitConfig ConcurrencyLimitConfig `config:"concurrency_limit"`
+	TimeoutConfig          TimeoutConfig          `config:"timeout"`
+	BodyConfig             BodyConfig             `config:"body"`
+	CompressionConfig      CompressionConfig      `config:"compression"`
+	SecurityConfig         SecurityConfig         `config:"security"`
+	ProxyConfig            ProxyConfig            `config:"proxy"`
+	TLSConfig              TLSConfig              `config:"tls"`
+	ProtocolConfig         ProtocolConfig         `config:"protocol"`
+	ListenerConfig         ListenerConfig         `config:"listener"`
+	RestartConfig          RestartConfig          `config:"restart"`
+	ReloadConfig           ReloadConfig           `config:"reload"`
+	LogConfig              LogConfig              `config:"log"`
+	Servers                []NamedServerConfig    `validate:"dive"`
+	// Environment defaults to APP_ENV, then Local.
+	Environment Environment
+	// Profiles replace the built-in profiles or declare those of custom
+	// environments.
+	Profiles   map[Environment]Profile
+	CORSConfig CORSConfig `config:"cors"`
 }
 
 // MetricsConfig configures the dedicated Prometheus metrics server. The metrics
@@ -25,8 +48,26 @@ type MetricsConfig struct {
 	Disabled bool
 	// Path is the HTTP path the metrics are exposed on. Defaults to "/metrics".
 	Path string
+	// Host is the interface the metrics server binds to. Defaults to all
+	// interfaces.
+	Host string
 	// Port is the port the metrics server listens on. Defaults to 9090.
-	Port int
+	Port int `validate:"gte=0,lte=65535"`
+	// Username and Password enable HTTP basic auth on the metrics endpoint
+	// when Username is set.
+	Username string
+	Password Secret
+	// BearerToken, when set, requires scrapes to send
+	// "Authorization: Bearer <token>". It may be combined with basic auth, in
+	// which case either credential is accepted.
+	BearerToken Secret
+	// TLSCertFile and TLSKeyFile serve the metrics endpoint over HTTPS when
+	// both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// AllowedCIDRs restricts scrapes to clients whose address falls within
+	// one of the listed networks. Bare IPs are accepted. Empty allows all.
+	AllowedCIDRs []string
 }
 
 func (c *MetricsConfig) escapePath() string {
@@ -56,6 +97,91 @@ func (c *MetricsConfig) escapePort() int {
 	return c.Port
 }
 
+func (c *MetricsConfig) escapeAddr() string {
+	host := strings.TrimSuffix(strings.ToLower(c.Host), "/")
+
+	return net.JoinHostPort(host, fmt.Sprint(c.escapePort()))
+}
+
+func (c *MetricsConfig) tlsEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+func (c *MetricsConfig) validate() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("metrics tls: TLSCertFile and TLSKeyFile must be set together")
+	}
+
+	return nil
+}
+
+// protected reports whether the metrics server restricts access by
+// credentials or client IP.
+func (c *MetricsConfig) protected() bool {
+	return c.Username != "" || !c.BearerToken.IsZero() || len(c.AllowedCIDRs) > 0
+}
+
+// restricted reports whether the metrics server is protected or binds a
+// loopback address, so only local or authorized clients reach it.
+func (c *MetricsConfig) restricted() bool {
+	if c.protected() {
+		return true
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(c.Host), "/")
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// servePprof reports whether pprof is mounted on the metrics server: the
+// profile must enable it and the metrics server must be restricted.
+func (c *ServerConfig) servePprof() bool {
+	return c.profile().Pprof && c.MetricsConfig.restricted()
+}
+
+func (c *MetricsConfig) scheme() string {
+	if c.tlsEnabled() {
+		return "https"
+	}
+
+	return "http"
+}
+
+// validate returns the configuration errors that New defers to Start.
+func (c *ServerConfig) validate() error {
+	errs := []error{
+		c.TLSConfig.validate(), c.MetricsConfig.validate(), c.RateLimitConfig.validate(), c.ProxyConfig.validate(),
+		c.validateSignals(), validateServers(c.Servers),
+	}
+	for _, n := range c.Servers {
+		if err := n.TLSConfig.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("server %s: %w", strconv.Quote(n.Name), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateSignals reports signals claimed by more than one feature.
+func (c *ServerConfig) validateSignals() error {
+	var errs []error
+	if c.ReloadConfig.enabled() && c.RestartConfig.Enabled && c.ReloadConfig.signal() == c.RestartConfig.signal() {
+		errs = append(errs, fmt.Errorf("signals: ReloadConfig and RestartConfig both use %v", c.ReloadConfig.signal()))
+	}
+
+	toggle := c.LogConfig.toggleSignal()
+	if c.LogConfig.EnableAdmin && ((c.ReloadConfig.enabled() && toggle == c.ReloadConfig.signal()) || (c.RestartConfig.Enabled && toggle == c.RestartConfig.signal())) {
+		errs = append(errs, fmt.Errorf("signals: LogConfig.ToggleSignal %v is also used by ReloadConfig or RestartConfig", toggle))
+	}
+
+	return errors.Join(errs...)
+}
+
 func (c *ServerConfig) escapePrefix() string {
 	return escapePath(c.PathPrefix)
 }
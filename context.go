@@ -1,10 +1,12 @@
 package echoext
 
 import (
+	"crypto/x509"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
@@ -37,6 +39,21 @@ type Context interface {
 	ParamUint64(name string) uint64
 
 	BindValidate(i interface{}) error
+
+	Subject() string
+	Claims() Claims
+	HasScope(scope string) bool
+	HasRole(role string) bool
+	Principal() Principal
+	Tenant() string
+	APIKey() *APIKey
+
+	IfMatch(etag string) error
+	CSPNonce() string
+	ClientCertificate() *x509.Certificate
+	ClientIdentity() string
+	Environment() Environment
+	Feature(name string) bool
 }
 
 var _ Context = (*context)(nil)
@@ -50,13 +67,19 @@ func (c *context) Attachment(file string, name string) error {
 	return c.parent.Attachment(file, name)
 }
 
-// Bind implements Context.
+// Bind binds the request into i. JSON bodies are decoded strictly when the
+// route enables BodyConfig.StrictJSON.
 func (c *context) Bind(i interface{}) error {
-	return c.parent.Bind(i)
+	if strict, _ := c.parent.Get(strictJSONKey).(bool); strict {
+		return bodyError(bindStrict(c.parent, i))
+	}
+
+	return bodyError(c.parent.Bind(i))
 }
 
+// BindValidate binds the request into i like Bind and validates it.
 func (c *context) BindValidate(i interface{}) error {
-	if err := c.parent.Bind(i); err != nil {
+	if err := c.Bind(i); err != nil {
 		return err
 	}
 
@@ -67,6 +90,117 @@ func (c *context) BindValidate(i interface{}) error {
 	return nil
 }
 
+// Subject returns the authenticated subject, or "" for anonymous requests.
+func (c *context) Subject() string {
+	return c.GetString(SubjectKey)
+}
+
+// Claims returns the validated JWT claims, or nil when the request was not
+// authenticated with a JWT.
+func (c *context) Claims() Claims {
+	if v, ok := c.parent.Get(ClaimsKey).(Claims); ok {
+		return v
+	}
+
+	return nil
+}
+
+// HasScope reports whether the authenticated principal was granted scope.
+func (c *context) HasScope(scope string) bool {
+	scopes, _ := c.parent.Get(ScopesKey).([]string)
+
+	return slices.Contains(scopes, scope)
+}
+
+// HasRole reports whether the authenticated principal holds role.
+func (c *context) HasRole(role string) bool {
+	roles, _ := c.parent.Get(RolesKey).([]string)
+
+	return slices.Contains(roles, role)
+}
+
+// Principal returns the authenticated caller. Its Subject is empty for
+// anonymous requests.
+func (c *context) Principal() Principal {
+	scopes, _ := c.parent.Get(ScopesKey).([]string)
+	roles, _ := c.parent.Get(RolesKey).([]string)
+
+	return Principal{
+		Subject: c.Subject(),
+		Scopes:  scopes,
+		Roles:   roles,
+		Claims:  c.Claims(),
+	}
+}
+
+// Tenant returns the caller's tenant, or "" when unknown.
+func (c *context) Tenant() string {
+	return c.GetString(TenantKey)
+}
+
+// APIKey returns the API key the request authenticated with, or nil.
+func (c *context) APIKey() *APIKey {
+	if v, ok := c.parent.Get(APIKeyKey).(*APIKey); ok {
+		return v
+	}
+
+	return nil
+}
+
+// IfMatch checks the If-Match precondition against the resource's current
+// ETag for optimistic concurrency. It returns nil when the header is absent
+// or matches, and a 412 error otherwise.
+func (c *context) IfMatch(etag string) error {
+	im := c.Request().Header.Get("If-Match")
+	if im == "" || etagListMatches(im, etag, false) {
+		return nil
+	}
+
+	return errPreconditionFailed
+}
+
+// CSPNonce returns the request's Content-Security-Policy nonce, generating
+// it on first use. Use it on inline <script> and <style> tags of HTML
+// responses whose policy contains CSPNoncePlaceholder.
+func (c *context) CSPNonce() string {
+	if v, ok := c.parent.Get(cspNonceKey).(string); ok {
+		return v
+	}
+
+	nonce := newCSPNonce()
+	c.parent.Set(cspNonceKey, nonce)
+
+	return nonce
+}
+
+// ClientCertificate returns the client certificate verified during the
+// mutual TLS handshake, or nil.
+func (c *context) ClientCertificate() *x509.Certificate {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	return state.PeerCertificates[0]
+}
+
+// ClientIdentity returns the identity of the verified client certificate:
+// its first URI SAN (such as a SPIFFE ID), else its first DNS SAN, else its
+// common name. It returns "" without a verified certificate.
+func (c *context) ClientIdentity() string {
+	cert := c.ClientCertificate()
+	switch {
+	case cert == nil:
+		return ""
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	default:
+		return cert.Subject.CommonName
+	}
+}
+
 // Blob implements Context.
 func (c *context) Blob(code int, contentType string, b []byte) error {
 	return c.parent.Blob(code, contentType, b)
@@ -240,7 +374,8 @@ func (c *context) QueryString() string {
 	return c.parent.QueryString()
 }
 
-// RealIP implements Context.
+// RealIP returns the client IP. Forwarding headers are only honoured from
+// the trusted proxies declared in ProxyConfig.
 func (c *context) RealIP() string {
 	return c.parent.RealIP()
 }
@@ -270,8 +405,13 @@ func (c *context) Response() *echo.Response {
 	return c.parent.Response()
 }
 
-// Scheme implements Context.
+// Scheme returns "https" or "http". Forwarded schemes are only honoured from
+// the trusted proxies declared in ProxyConfig.
 func (c *context) Scheme() string {
+	if p, ok := c.parent.Get(proxyKey).(*proxyResolver); ok {
+		return p.scheme(c.Request())
+	}
+
 	return c.parent.Scheme()
 }
 
@@ -0,0 +1,190 @@
+package echoext
+
+import (
+	"crypto/tls"
+	"os"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// envKey holds the server's Environment so Context.Environment can return
+// it.
+const envKey = "echoext.environment"
+
+// Environment is the deployment environment the server runs in. Values
+// other than the predefined ones are custom environments.
+type Environment string
+
+const (
+	Local       Environment = "local"
+	Development Environment = "development"
+	Staging     Environment = "staging"
+	Production  Environment = "production"
+)
+
+func (e Environment) IsLocal() bool {
+	return e == Local
+}
+
+func (e Environment) IsProduction() bool {
+	return e == Production
+}
+
+// LogFormat selects how the access log and server logger write entries.
+type LogFormat string
+
+const (
+	JSONLogFormat LogFormat = "json"
+	TextLogFormat LogFormat = "text"
+)
+
+// textLogFormat is the access log line used by TextLogFormat.
+const textLogFormat = "${time_rfc3339} ${status} ${method} ${uri} ${latency_human} ${remote_ip} ${error}\n"
+
+// Profile holds the defaults that depend on the environment.
+type Profile struct {
+	// LogFormat defaults to JSONLogFormat.
+	LogFormat LogFormat
+	// Debug logs at debug level.
+	Debug bool
+	// Swagger serves the Swagger docs.
+	Swagger bool
+	// ErrorDetail exposes internal error messages in error responses.
+	ErrorDetail bool
+	// CORSOrigins are the origins allowed when CORSConfig.AllowOrigins is
+	// empty. Without any, cross-origin requests are not allowed.
+	CORSOrigins []string
+	// Pprof serves net/http/pprof under /debug/pprof/ on the metrics server,
+	// behind the same access controls as the metrics. It is only mounted
+	// when the metrics server binds a loopback address or sets Username,
+	// BearerToken or AllowedCIDRs.
+	Pprof bool
+}
+
+// DefaultProfile returns the built-in profile for env. Only local serves
+// pprof. Staging and custom environments share one profile: JSON logs,
+// Swagger and any CORS origin, but no debug logging or error detail.
+// Production keeps allowing any CORS origin, as earlier releases did, and
+// warns about it at startup.
+func DefaultProfile(env Environment) Profile {
+	switch env {
+	case Local:
+		return Profile{
+			LogFormat:   TextLogFormat,
+			Debug:       true,
+			Swagger:     true,
+			ErrorDetail: true,
+			CORSOrigins: []string{"*"},
+			Pprof:       true,
+		}
+	case Development:
+		return Profile{
+			LogFormat:   JSONLogFormat,
+			Debug:       true,
+			Swagger:     true,
+			ErrorDetail: true,
+			CORSOrigins: []string{"*"},
+		}
+	case Production:
+		return Profile{LogFormat: JSONLogFormat, CORSOrigins: []string{"*"}}
+	default:
+		return Profile{
+			LogFormat:   JSONLogFormat,
+			Swagger:     true,
+			CORSOrigins: []string{"*"},
+		}
+	}
+}
+
+// CORSConfig overrides the CORS defaults of the environment profile.
+type CORSConfig struct {
+	// AllowOrigins defaults to the profile's CORSOrigins.
+	AllowOrigins []string
+	// DisableCredentials stops sending Access-Control-Allow-Credentials.
+	DisableCredentials bool
+}
+
+// environment returns the configured environment, falling back to APP_ENV
+// and then Local.
+func (c *ServerConfig) environment() Environment {
+	if c.Environment != "" {
+		return c.Environment
+	}
+
+	if env := os.Getenv("APP_ENV"); env != "" {
+		return Environment(env)
+	}
+
+	return Local
+}
+
+// profile returns the profile of the configured environment, preferring
+// the ones declared in Profiles.
+func (c *ServerConfig) profile() Profile {
+	env := c.environment()
+	if p, ok := c.Profiles[env]; ok {
+		return p
+	}
+
+	return DefaultProfile(env)
+}
+
+// corsOrigins returns the allowed CORS origins.
+func (c *ServerConfig) corsOrigins() []string {
+	if len(c.CORSConfig.AllowOrigins) > 0 {
+		return c.CORSConfig.AllowOrigins
+	}
+
+	return c.profile().CORSOrigins
+}
+
+// productionWarnings lists the settings that are unsafe in production.
+func (c *ServerConfig) productionWarnings() []string {
+	if !c.environment().IsProduction() {
+		return nil
+	}
+
+	p := c.profile()
+
+	var warnings []string
+	origins := c.corsOrigins()
+	if slices.Contains(origins, "*") && !c.CORSConfig.DisableCredentials {
+		warnings = append(warnings, "CORS allows any origin with credentials")
+	}
+
+	if p.Swagger && c.SwaggerConfig.Credentials.Value() == "" {
+		warnings = append(warnings, "Swagger docs are served without credentials")
+	}
+
+	if p.ErrorDetail {
+		warnings = append(warnings, "error responses expose internal error messages")
+	}
+
+	if p.Debug {
+		warnings = append(warnings, "debug logging is enabled")
+	}
+
+	if c.TLSConfig.enabled() && c.TLSConfig.MinVersion != 0 && c.TLSConfig.MinVersion < tls.VersionTLS12 {
+		warnings = append(warnings, "TLS allows versions older than 1.2")
+	}
+
+	return warnings
+}
+
+// setEnvironment exposes env to handlers through Context.Environment.
+func setEnvironment(env Environment) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(envKey, env)
+			return next(c)
+		}
+	}
+}
+
+// Environment returns the environment the server runs in.
+func (c *context) Environment() Environment {
+	env, _ := c.parent.Get(envKey).(Environment)
+
+	return env
+}
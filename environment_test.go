@@ -0,0 +1,119 @@
+package echoext
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestErrorDetail(t *testing.T) {
+	tests := []struct {
+		env       Environment
+		wantError bool
+	}{
+		{env: Local, wantError: true},
+		{env: Development, wantError: true},
+		{env: Staging},
+		{env: Production},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.env), func(t *testing.T) {
+			srv := New(ServerConfig{Environment: tt.env, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("items", func(g *Group) {
+				g.GET("", func(c Context) error {
+					return errors.New("db: connection refused")
+				})
+				g.GET("/json", func(c Context) error {
+					return c.JSON(http.StatusOK, M{"id": 1})
+				})
+			})
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("error body %q: %v", rec.Body, err)
+			}
+
+			if rec.Code != http.StatusInternalServerError || body["message"] != "Internal Server Error" {
+				t.Fatalf("error response = %d %s", rec.Code, rec.Body)
+			}
+
+			if _, ok := body["error"]; ok != tt.wantError {
+				t.Fatalf("error detail present = %v, want %v (%s)", ok, tt.wantError, rec.Body)
+			}
+
+			// JSON responses are compact whatever the profile, so their
+			// ETags match across environments.
+			rec = httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/json", nil))
+
+			if got := strings.TrimSpace(rec.Body.String()); got != `{"id":1}` {
+				t.Fatalf("JSON body = %q, want compact", got)
+			}
+		})
+	}
+}
+
+func TestDefaultProfilePprofOnlyLocal(t *testing.T) {
+	for _, env := range []Environment{Local, Development, Staging, Production, "qa"} {
+		if got := DefaultProfile(env).Pprof; got != (env == Local) {
+			t.Errorf("%s: Pprof = %v", env, got)
+		}
+	}
+}
+
+func TestProductionWarnsAboutDefaultCORS(t *testing.T) {
+	c := ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}}
+	if !slices.Equal(c.corsOrigins(), []string{"*"}) {
+		t.Fatalf("production CORS origins = %q, want the earlier default *", c.corsOrigins())
+	}
+
+	if !slices.ContainsFunc(c.productionWarnings(), func(w string) bool { return strings.Contains(w, "CORS allows any origin") }) {
+		t.Fatalf("no CORS warning in %q", c.productionWarnings())
+	}
+
+	c.CORSConfig.AllowOrigins = []string{"https://app.example.com"}
+	if slices.ContainsFunc(c.productionWarnings(), func(w string) bool { return strings.Contains(w, "CORS") }) {
+		t.Fatalf("CORS warning with origins configured: %q", c.productionWarnings())
+	}
+}
+
+func TestPprofRequiresRestrictedMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     Environment
+		metrics MetricsConfig
+		want    int
+	}{
+		{name: "local on all interfaces", env: Local, want: http.StatusNotFound},
+		{name: "local on loopback", env: Local, metrics: MetricsConfig{Host: "127.0.0.1"}, want: http.StatusOK},
+		{name: "local on localhost", env: Local, metrics: MetricsConfig{Host: "localhost"}, want: http.StatusOK},
+		{name: "local allowlisted", env: Local, metrics: MetricsConfig{AllowedCIDRs: []string{"192.0.2.0/24"}}, want: http.StatusOK},
+		{name: "production on loopback", env: Production, metrics: MetricsConfig{Host: "127.0.0.1"}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: tt.env, MetricsConfig: tt.metrics}).(extServer)
+
+			ms, err := newMetricsServer(srv.config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			ms.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("GET /debug/pprof/ = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
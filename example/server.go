@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/BacoFoods/echoext"
@@ -163,20 +164,16 @@ func LoggingMiddleware(next echoext.HandlerFunc) echoext.HandlerFunc {
 	}
 }
 
-// AuthMiddleware is a simple middleware to check for authentication
-func AuthMiddleware(next echoext.HandlerFunc) echoext.HandlerFunc {
-	return func(c echoext.Context) error {
-		// In a real app, you would check for a valid token
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, echoext.M{"error": "Authorization required"})
-		}
-
-		// For demo purposes, let's set a user ID
-		c.Set("id", 1)
-
-		return next(c)
+// jwtSecret returns the HMAC key used to verify bearer tokens, read from
+// JWT_SECRET. There is no fallback, since a well-known key would let anyone
+// mint accepted tokens.
+func jwtSecret() ([]byte, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is not set")
 	}
+
+	return []byte(secret), nil
 }
 
 // parseInt is a helper function to convert string to int
@@ -190,6 +187,11 @@ func parseInt(s string) (int, error) {
 // an interrupt/terminate signal, then gracefully shuts down.
 func StartServer() error {
 	// Create a server with custom configuration
+	secret, err := jwtSecret()
+	if err != nil {
+		return err
+	}
+
 	config := echoext.ServerConfig{
 		PathPrefix:       "/api/v1",
 		Host:             "localhost",
@@ -208,14 +210,20 @@ func StartServer() error {
 
 	handler := NewHandler()
 
+	// Validate bearer tokens on write routes
+	auth := echoext.JWT(echoext.JWTConfig{
+		Secret:    secret,
+		ClockSkew: 30 * time.Second,
+	})
+
 	// Create a users group
 	server.Group("/users", func(g *echoext.Group) {
 		// Register routes with our custom handlers and middleware
 		g.GET("", handler.GetUsers, LoggingMiddleware)
 		g.GET("/:id", handler.GetUser, LoggingMiddleware)
-		g.POST("", handler.CreateUser, LoggingMiddleware, AuthMiddleware)
-		g.PUT("/:id", handler.UpdateUser, LoggingMiddleware, AuthMiddleware)
-		g.DELETE("/:id", handler.DeleteUser, LoggingMiddleware, AuthMiddleware)
+		g.POST("", handler.CreateUser, LoggingMiddleware, auth)
+		g.PUT("/:id", handler.UpdateUser, LoggingMiddleware, auth)
+		g.DELETE("/:id", handler.DeleteUser, LoggingMiddleware, auth)
 	})
 
 	// Start the server (blocks until shutdown)
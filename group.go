@@ -1,12 +1,22 @@
 package echoext
 
-import "github.com/labstack/echo/v4"
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
 
 type HandlerFunc func(c Context) error
 type MiddlewareFunc func(next HandlerFunc) HandlerFunc
 
 type Group struct {
 	*echo.Group
+
+	authz   []Requirement
+	timeout time.Duration
+	routes  *routeTable
 }
 
 // adaptMiddleware converts our custom middleware to echo middleware
@@ -39,7 +49,59 @@ func (g *Group) NewGroup(prefix string, middlewares ...MiddlewareFunc) *Group {
 		echoMiddlewares[i] = adaptMiddleware(m)
 	}
 
-	return &Group{g.Group.Group(p, echoMiddlewares...)}
+	return &Group{
+		Group:   g.Group.Group(p, echoMiddlewares...),
+		authz:   slices.Clone(g.authz),
+		timeout: g.timeout,
+		routes:  g.routes,
+	}
+}
+
+// WithTimeout returns g with d as the deadline of the routes registered
+// through it and its subgroups. The deadline is recorded in the route table
+// and applied as if Timeout(d) were the first route middleware, so a route's
+// own Timeout still wins.
+func (g *Group) WithTimeout(d time.Duration) *Group {
+	sub := *g
+	sub.timeout = d
+
+	return &sub
+}
+
+// Require returns g with reqs added to the requirements of the routes
+// registered through it and its subgroups. Requirements are recorded in the
+// route table and enforced after the route middleware, right before the
+// handler, so authentication may be group or route middleware.
+func (g *Group) Require(reqs ...Requirement) *Group {
+	sub := *g
+	sub.authz = append(slices.Clip(g.authz), reqs...)
+
+	return &sub
+}
+
+// add registers a route, recording it in the server's route table along with
+// its timeout and requirements.
+func (g *Group) add(method, path string, h HandlerFunc, m []MiddlewareFunc) *echo.Route {
+	if g.timeout > 0 {
+		m = append([]MiddlewareFunc{Timeout(g.timeout)}, m...)
+	}
+
+	if len(g.authz) > 0 {
+		m = slices.Clip(m)
+		for _, req := range g.authz {
+			m = append(m, authorize(req))
+		}
+	}
+
+	r := g.Group.Add(method, path, adaptHandler(h, m...))
+	g.routes.add(RouteInfo{
+		Method:        method,
+		Path:          r.Path,
+		Authorization: slices.Clone(g.authz),
+		Timeout:       g.timeout,
+	})
+
+	return r
 }
 
 // applyMiddleware wraps the handler with all middleware functions
@@ -52,8 +114,9 @@ func applyMiddleware(h HandlerFunc, middleware ...MiddlewareFunc) HandlerFunc {
 
 // adaptHandler converts our custom handler to an echo.HandlerFunc
 func adaptHandler(h HandlerFunc, middleware ...MiddlewareFunc) echo.HandlerFunc {
-	// Apply all middleware to the handler
-	handler := applyMiddleware(h, middleware...)
+	// Apply all middleware to the handler. Checks that depend on the
+	// innermost configuration run last, right before the handler.
+	handler := applyMiddleware(enforceBody(h), middleware...)
 
 	// Convert to echo.HandlerFunc
 	return func(c echo.Context) error {
@@ -64,25 +127,25 @@ func adaptHandler(h HandlerFunc, middleware ...MiddlewareFunc) echo.HandlerFunc
 
 // GET registers a new GET route for the group with a custom Context handler.
 func (g *Group) GET(path string, h HandlerFunc, m ...MiddlewareFunc) *echo.Route {
-	return g.Group.GET(path, adaptHandler(h, m...))
+	return g.add(http.MethodGet, path, h, m)
 }
 
 // POST registers a new POST route for the group with a custom Context handler.
 func (g *Group) POST(path string, h HandlerFunc, m ...MiddlewareFunc) *echo.Route {
-	return g.Group.POST(path, adaptHandler(h, m...))
+	return g.add(http.MethodPost, path, h, m)
 }
 
 // PUT registers a new PUT route for the group with a custom Context handler.
 func (g *Group) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *echo.Route {
-	return g.Group.PUT(path, adaptHandler(h, m...))
+	return g.add(http.MethodPut, path, h, m)
 }
 
 // DELETE registers a new DELETE route for the group with a custom Context handler.
 func (g *Group) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *echo.Route {
-	return g.Group.DELETE(path, adaptHandler(h, m...))
+	return g.add(http.MethodDelete, path, h, m)
 }
 
 // PATCH registers a new PATCH route for the group with a custom Context handler.
 func (g *Group) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *echo.Route {
-	return g.Group.PATCH(path, adaptHandler(h, m...))
+	return g.add(http.MethodPatch, path, h, m)
 }
@@ -1,8 +1,126 @@
 package echoext
 
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
 // M is a helper type for map[string]any
 type M map[string]any
 
 func ErrM(err error) M {
 	return M{"error": err.Error()}
 }
+
+// newHTTPError builds an echo HTTP error whose body follows the ErrM shape,
+// so middleware rejections render the same as handler errors.
+func newHTTPError(code int, msg string) *echo.HTTPError {
+	return echo.NewHTTPError(code, M{"error": msg})
+}
+
+// perRequestHeaders describe the exchange that produced a response rather
+// than the response itself, so they are not stored for replay: hop-by-hop
+// headers, cookies, rate limit state, request IDs and the CORS headers the
+// CORS middleware sets for each request's Origin.
+var perRequestHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Set-Cookie", "Date", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+	echo.HeaderXRequestID, "X-Cache", "Idempotent-Replayed",
+	echo.HeaderAccessControlAllowOrigin, echo.HeaderAccessControlAllowCredentials, echo.HeaderAccessControlExposeHeaders,
+}
+
+// storableHeader returns a copy of h without per-request headers and the
+// headers listed in Connection, for storing a response to be replayed to
+// other requests.
+func storableHeader(h http.Header) http.Header {
+	stored := h.Clone()
+	for _, f := range h.Values("Connection") {
+		for _, k := range strings.Split(f, ",") {
+			stored.Del(strings.TrimSpace(k))
+		}
+	}
+
+	for _, k := range perRequestHeaders {
+		stored.Del(k)
+	}
+
+	return stored
+}
+
+// writeStoredResponse replays a previously captured response. Headers already
+// set on the current response are kept unless the stored ones override them.
+func writeStoredResponse(c echo.Context, status int, header http.Header, body []byte) error {
+	h := c.Response().Header()
+	for k, v := range header {
+		h[k] = slices.Clone(v)
+	}
+
+	c.Response().WriteHeader(status)
+	_, err := c.Response().Write(body)
+
+	return err
+}
+
+// parseCIDRs parses a list of CIDR blocks. Bare IPs are accepted and treated
+// as single-host networks.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, raw := range cidrs {
+		cidr := strings.TrimSpace(raw)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", raw)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", raw)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// containsIP reports whether ip falls within any of nets.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP extracts the IP from a "host:port" remote address.
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// secureCompare compares two secrets in constant time.
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,261 @@
+package echoext
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cacheControlKey holds the Cache-Control value of the innermost CacheControl
+// middleware.
+const cacheControlKey = "echoext.cache_control"
+
+var errPreconditionFailed = newHTTPError(http.StatusPreconditionFailed, "resource has been modified")
+
+// HTTPCacheConfig configures conditional request handling.
+type HTTPCacheConfig struct {
+	// Weak emits weak ETags (W/"...") instead of strong ones.
+	Weak bool
+}
+
+// HTTPCache returns a middleware that adds an ETag to successful JSON
+// responses to GET and HEAD requests and answers conditional requests with
+// 304 Not Modified. If-None-Match is checked against the ETag; otherwise
+// If-Modified-Since is checked against a Last-Modified header set by the
+// handler. Responses that are flushed while being written (streams, SSE) are
+// passed through untouched.
+func HTTPCache(cfg HTTPCacheConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next(c)
+			}
+
+			res := c.Response()
+			bw := &bufferWriter{ResponseWriter: res.Writer}
+			res.Writer = bw
+			defer func() { res.Writer = bw.ResponseWriter }()
+
+			if err := next(c); err != nil {
+				bw.release()
+				return err
+			}
+
+			if bw.passthrough || bw.status != http.StatusOK || !isJSON(res.Header().Get(echo.HeaderContentType)) {
+				bw.release()
+				return nil
+			}
+
+			h := res.Header()
+			if h.Get("ETag") == "" {
+				h.Set("ETag", computeETag(bw.buf.Bytes(), cfg.Weak))
+			}
+
+			if notModified(req, h) {
+				h.Del(echo.HeaderContentLength)
+				h.Del(echo.HeaderContentType)
+				bw.ResponseWriter.WriteHeader(http.StatusNotModified)
+				res.Status = http.StatusNotModified
+				return nil
+			}
+
+			bw.release()
+
+			return nil
+		}
+	}
+}
+
+// CacheControl returns a middleware setting the Cache-Control header on
+// successful (2xx and 304) responses. The innermost CacheControl wins, and a
+// header set explicitly by the handler is left untouched.
+func CacheControl(directives string) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			_, registered := c.Get(cacheControlKey).(string)
+			c.Set(cacheControlKey, directives)
+
+			if !registered {
+				res := c.Response()
+				res.Before(func() {
+					if res.Status >= 300 && res.Status != http.StatusNotModified {
+						return
+					}
+
+					if res.Header().Get(echo.HeaderCacheControl) == "" {
+						res.Header().Set(echo.HeaderCacheControl, c.GetString(cacheControlKey))
+					}
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// JSONETag returns the strong ETag HTTPCache would compute for v rendered
+// with c.JSON on this request, so write handlers can compare it with IfMatch.
+// It hashes the bytes c.JSON writes: the engine's JSONSerializer, indented as
+// echo does in debug mode or for ?pretty.
+func JSONETag(c Context, v any) (string, error) {
+	indent := ""
+	if _, pretty := c.QueryParams()["pretty"]; c.Echo().Debug || pretty {
+		indent = "  "
+	}
+
+	w := &renderWriter{header: http.Header{}}
+	if err := c.Echo().JSONSerializer.Serialize(c.Echo().NewContext(c.Request(), w), v, indent); err != nil {
+		return "", err
+	}
+
+	return computeETag(w.buf.Bytes(), false), nil
+}
+
+// renderWriter captures a body rendered outside the real response.
+type renderWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (w *renderWriter) Header() http.Header { return w.header }
+
+func (w *renderWriter) Write(b []byte) (int, error) { return w.buf.Write(b) }
+
+func (w *renderWriter) WriteHeader(int) {}
+
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+
+	return tag
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"))
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since, as
+// RFC 9110 prescribes.
+func notModified(req *http.Request, h http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, h.Get("ETag"), true)
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// etagListMatches reports whether etag matches an entry of the If-Match or
+// If-None-Match list. Weak comparison ignores the W/ prefix; strong
+// comparison never matches weak tags. An entry also matches when it is etag
+// with the encoding suffix of Compression.
+func etagListMatches(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate, etag := strings.TrimPrefix(candidate, "W/"), strings.TrimPrefix(etag, "W/")
+			if candidate == etag || decodedETag(candidate) == etag {
+				return true
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+
+		if candidate == etag || decodedETag(candidate) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferWriter holds the status and body of a response until release, so
+// middleware can inspect or rewrite it. Flushing switches it to pass-through
+// so streaming responses keep working.
+type bufferWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.buf.Write(b)
+}
+
+// Flush writes what was buffered and switches to pass-through.
+func (w *bufferWriter) Flush() {
+	w.release()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *bufferWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// release writes the buffered response to the underlying writer and switches
+// to pass-through. It is a no-op once released.
+func (w *bufferWriter) release() {
+	if w.passthrough {
+		return
+	}
+
+	w.passthrough = true
+	if w.status == 0 {
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
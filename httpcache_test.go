@@ -0,0 +1,194 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var cachedMenu = map[string]string{"name": "lunch"}
+
+// menuETag is the strong ETag of cachedMenu as c.JSON writes it.
+var menuETag = computeETag([]byte(`{"name":"lunch"}`+"\n"), false)
+
+func TestHTTPCache(t *testing.T) {
+	lastModified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		cfg        HTTPCacheConfig
+		method     string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantETag   string
+		noETag     bool
+	}{
+		{name: "etag", path: "/menu", wantStatus: http.StatusOK, wantETag: menuETag},
+		{name: "weak etag", cfg: HTTPCacheConfig{Weak: true}, path: "/menu", wantStatus: http.StatusOK, wantETag: "W/" + menuETag},
+		{name: "if-none-match", path: "/menu", header: map[string]string{"If-None-Match": menuETag}, wantStatus: http.StatusNotModified, wantETag: menuETag},
+		{name: "if-none-match in a list", path: "/menu", header: map[string]string{"If-None-Match": `"other", ` + menuETag}, wantStatus: http.StatusNotModified, wantETag: menuETag},
+		{name: "if-none-match compares weakly", path: "/menu", header: map[string]string{"If-None-Match": "W/" + menuETag}, wantStatus: http.StatusNotModified, wantETag: menuETag},
+		{name: "if-none-match star", path: "/menu", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified, wantETag: menuETag},
+		{name: "if-none-match stale", path: "/menu", header: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK, wantETag: menuETag},
+		{
+			name:       "if-none-match wins over if-modified-since",
+			path:       "/menu/dated",
+			header:     map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			wantStatus: http.StatusOK,
+			wantETag:   menuETag,
+		},
+		{name: "if-modified-since", path: "/menu/dated", header: map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, wantStatus: http.StatusNotModified, wantETag: menuETag},
+		{name: "modified since", path: "/menu/dated", header: map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)}, wantStatus: http.StatusOK, wantETag: menuETag},
+		{name: "not json", path: "/menu/text", wantStatus: http.StatusOK, noETag: true},
+		{name: "not a read", method: http.MethodPost, path: "/menu", wantStatus: http.StatusOK, noETag: true},
+		{name: "pretty has its own etag", path: "/menu?pretty", header: map[string]string{"If-None-Match": menuETag}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("menu", func(g *Group) {
+				g.GET("", func(c Context) error { return c.JSON(http.StatusOK, cachedMenu) })
+				g.POST("", func(c Context) error { return c.JSON(http.StatusOK, cachedMenu) })
+				g.GET("/dated", func(c Context) error {
+					c.Response().Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+					return c.JSON(http.StatusOK, cachedMenu)
+				})
+				g.GET("/text", func(c Context) error { return c.String(http.StatusOK, "lunch") })
+			}, HTTPCache(tt.cfg))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantETag != "" && rec.Header().Get("ETag") != tt.wantETag {
+				t.Fatalf("ETag = %q, want %q", rec.Header().Get("ETag"), tt.wantETag)
+			}
+
+			if tt.noETag && rec.Header().Get("ETag") != "" {
+				t.Fatalf("ETag = %q, want none", rec.Header().Get("ETag"))
+			}
+
+			if rec.Code == http.StatusNotModified && rec.Body.Len() > 0 {
+				t.Fatalf("304 carries a body: %q", rec.Body)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		ifMatch string
+		// etagOf is a path whose GET ETag is sent as If-Match.
+		etagOf string
+		want   int
+	}{
+		{name: "absent", path: "/menu", want: http.StatusNoContent},
+		{name: "current", path: "/menu", ifMatch: menuETag, want: http.StatusNoContent},
+		{name: "in a list", path: "/menu", ifMatch: `"other", ` + menuETag, want: http.StatusNoContent},
+		{name: "star", path: "/menu", ifMatch: "*", want: http.StatusNoContent},
+		{name: "stale", path: "/menu", ifMatch: `"other"`, want: http.StatusPreconditionFailed},
+		{name: "weak never matches", path: "/menu", ifMatch: "W/" + menuETag, want: http.StatusPreconditionFailed},
+		{name: "pretty etag on a pretty request", path: "/menu?pretty", etagOf: "/menu?pretty", want: http.StatusNoContent},
+		{name: "pretty etag on a plain request", path: "/menu", etagOf: "/menu?pretty", want: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("menu", func(g *Group) {
+				g.GET("", func(c Context) error { return c.JSON(http.StatusOK, cachedMenu) })
+				g.PUT("", func(c Context) error {
+					current, err := JSONETag(c, cachedMenu)
+					if err != nil {
+						return err
+					}
+
+					if err := c.IfMatch(current); err != nil {
+						return err
+					}
+
+					return c.NoContent(http.StatusNoContent)
+				})
+			}, HTTPCache(HTTPCacheConfig{}))
+
+			ifMatch := tt.ifMatch
+			if tt.etagOf != "" {
+				rec := httptest.NewRecorder()
+				srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.etagOf, nil))
+				ifMatch = rec.Header().Get("ETag")
+			}
+
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		want   string
+	}{
+		{name: "group directive", path: "/menu", want: "no-cache"},
+		{name: "innermost wins", path: "/menu/public", want: "public, max-age=60"},
+		{name: "handler header kept", path: "/menu/own", want: "private"},
+		{name: "not modified", path: "/menu", header: map[string]string{"If-None-Match": menuETag}, want: "no-cache"},
+		{name: "error", path: "/menu/missing"},
+	}
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("menu", func(g *Group) {
+		g.GET("", func(c Context) error { return c.JSON(http.StatusOK, cachedMenu) })
+		g.GET("/public", func(c Context) error { return c.JSON(http.StatusOK, cachedMenu) }, CacheControl("public, max-age=60"))
+		g.GET("/own", func(c Context) error {
+			c.Response().Header().Set("Cache-Control", "private")
+			return c.JSON(http.StatusOK, cachedMenu)
+		})
+		g.GET("/missing", func(c Context) error { return newHTTPError(http.StatusNotFound, "menu not found") })
+	}, HTTPCache(HTTPCacheConfig{}), CacheControl("no-cache"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.want {
+				t.Fatalf("Cache-Control = %q, want %q (status %d)", got, tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package echoext
+
+import (
+	"bytes"
+	stdcontext "context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	errIdempotencyInFlight    = newHTTPError(http.StatusConflict, "a request with this Idempotency-Key is already in progress")
+	errIdempotencyMismatch    = newHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+	errIdempotencyUnavailable = newHTTPError(http.StatusServiceUnavailable, "idempotency store unavailable")
+)
+
+// ErrIdempotencyLockLost is returned by IdempotencyStore.Complete and Release
+// when the key is no longer reserved by the given token, because its
+// reservation expired and another request took it.
+var ErrIdempotencyLockLost = errors.New("idempotency key is no longer reserved by this request")
+
+// IdempotencyRecord is the state kept for an idempotency key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Token identifies the reservation of an in-flight record.
+	Token string
+	// Completed is false while the first request is still being handled.
+	Completed  bool
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyStore keeps idempotency records. The operations map directly to
+// an atomic insert-if-absent (Redis SET NX, SQL INSERT ... ON CONFLICT DO
+// NOTHING) and a compare-and-set update and delete on the record's Token.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Begin reserves key for a request with fingerprint for ttl. When the
+	// key is new it stores an in-flight record with a new unique Token and
+	// returns it with acquired true; otherwise it returns the existing
+	// record.
+	Begin(ctx stdcontext.Context, key, fingerprint string, ttl time.Duration) (rec *IdempotencyRecord, acquired bool, err error)
+	// Complete stores the final response for key if key is still reserved
+	// by token, and returns ErrIdempotencyLockLost otherwise.
+	Complete(ctx stdcontext.Context, key, token string, rec *IdempotencyRecord, ttl time.Duration) error
+	// Release forgets key so the request can be retried, if key is still
+	// reserved by token, and returns ErrIdempotencyLockLost otherwise.
+	Release(ctx stdcontext.Context, key, token string) error
+}
+
+// IdempotencyConfig configures the idempotency middleware.
+type IdempotencyConfig struct {
+	// Header carries the client's key. Defaults to "Idempotency-Key".
+	Header string
+	// Methods the middleware applies to. Defaults to POST and PATCH.
+	Methods []string
+	// Store defaults to a new in-memory store.
+	Store IdempotencyStore
+	// TTL is how long responses are kept for replay. Defaults to 24 hours.
+	TTL time.Duration
+	// LockTTL is how long the key stays reserved while the first request is
+	// handled, so a crashed request frees it. Defaults to one minute, or
+	// the time left before the request's deadline when longer.
+	LockTTL time.Duration
+	// MaxBodyBytes bounds the request body read for fingerprinting. Defaults
+	// to 1 MiB.
+	MaxBodyBytes int64
+	// FailOpen handles requests without idempotency when the store fails.
+	// By default they are rejected with 503, since handling them could
+	// repeat side effects.
+	FailOpen bool
+}
+
+func (c IdempotencyConfig) withDefaults() IdempotencyConfig {
+	if c.Header == "" {
+		c.Header = "Idempotency-Key"
+	}
+
+	if len(c.Methods) == 0 {
+		c.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+
+	if c.Store == nil {
+		c.Store = NewMemoryIdempotencyStore()
+	}
+
+	if c.TTL <= 0 {
+		c.TTL = 24 * time.Hour
+	}
+
+	if c.LockTTL <= 0 {
+		c.LockTTL = time.Minute
+	}
+
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 1 << 20
+	}
+
+	return c
+}
+
+// Idempotency returns a middleware honouring the Idempotency-Key header. The
+// first response for a key is stored and replayed, with an
+// "Idempotent-Replayed: true" header, for retries of the same request. A
+// retry while the first request is still running fails with 409, and reusing
+// a key for a different request body fails with 422. Errors and 5xx
+// responses are not stored, so the client can retry them. Per-request headers
+// such as Set-Cookie, RateLimit-* and X-Request-ID are not replayed.
+// Requests without the header or without an authenticated subject are
+// handled normally, since anonymous clients would share one key space.
+func Idempotency(cfg IdempotencyConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			key := req.Header.Get(cfg.Header)
+			if key == "" || c.Subject() == "" || !slices.Contains(cfg.Methods, req.Method) {
+				return next(c)
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, cfg.MaxBodyBytes))
+			if err != nil {
+				return bodyError(err)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Keys are scoped to the authenticated caller and route so
+			// clients cannot collide with each other.
+			storeKey := c.Subject() + "|" + req.Method + " " + c.Path() + "|" + key
+			fingerprint := requestFingerprint(req, body)
+
+			ctx := req.Context()
+			rec, acquired, err := cfg.Store.Begin(ctx, storeKey, fingerprint, lockTTL(ctx, cfg.LockTTL))
+			if err != nil {
+				c.Logger().Errorf("idempotency store: %v", err)
+				if cfg.FailOpen {
+					return next(c)
+				}
+
+				return errIdempotencyUnavailable
+			}
+
+			if !acquired {
+				switch {
+				case rec.Fingerprint != fingerprint:
+					return errIdempotencyMismatch
+				case !rec.Completed:
+					return errIdempotencyInFlight
+				default:
+					c.Response().Header().Set("Idempotent-Replayed", "true")
+					return writeStoredResponse(c, rec.StatusCode, rec.Header, rec.Body)
+				}
+			}
+
+			res := c.Response()
+			capture := &captureWriter{ResponseWriter: res.Writer}
+			res.Writer = capture
+
+			err = next(c)
+
+			res.Writer = capture.ResponseWriter
+
+			if err != nil || res.Status >= http.StatusInternalServerError || !res.Committed {
+				if rErr := cfg.Store.Release(ctx, storeKey, rec.Token); rErr != nil {
+					c.Logger().Errorf("idempotency store: %v", rErr)
+				}
+
+				return err
+			}
+
+			done := &IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				StatusCode:  res.Status,
+				Header:      storableHeader(res.Header()),
+				Body:        capture.body.Bytes(),
+			}
+
+			if cErr := cfg.Store.Complete(ctx, storeKey, rec.Token, done, cfg.TTL); cErr != nil {
+				c.Logger().Errorf("idempotency store: %v", cErr)
+			}
+
+			return nil
+		}
+	}
+}
+
+// lockTTL returns how long to reserve a key: d, or until the request's
+// deadline when that is later.
+func lockTTL(ctx stdcontext.Context, d time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return max(d, time.Until(deadline))
+	}
+
+	return d
+}
+
+// requestFingerprint hashes the parts of the request that must match for a
+// retry to be considered identical.
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// captureWriter tees the response body so it can be stored.
+type captureWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. Records are not
+// shared between replicas.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	rec     IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: map[string]memoryIdempotencyEntry{}}
+}
+
+// Begin implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Begin(_ stdcontext.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if e, ok := s.records[key]; ok && now.Before(e.expires) {
+		rec := e.rec
+		return &rec, false, nil
+	}
+
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+
+	rec := IdempotencyRecord{Fingerprint: fingerprint, Token: hex.EncodeToString(token)}
+	s.records[key] = memoryIdempotencyEntry{rec: rec, expires: now.Add(ttl)}
+
+	return &rec, true, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Complete(_ stdcontext.Context, key, token string, rec *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.reserved(key, token) {
+		return ErrIdempotencyLockLost
+	}
+
+	done := *rec
+	done.Token = ""
+	s.records[key] = memoryIdempotencyEntry{rec: done, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Release(_ stdcontext.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.reserved(key, token) {
+		return ErrIdempotencyLockLost
+	}
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// reserved reports whether key holds an unexpired in-flight record with
+// token. Callers must hold s.mu.
+func (s *MemoryIdempotencyStore) reserved(key, token string) bool {
+	e, ok := s.records[key]
+
+	return ok && !e.rec.Completed && e.rec.Token == token && time.Now().Before(e.expires)
+}
+
+// sweep evicts expired records at most once a minute. Callers must hold s.mu.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+
+	s.lastSweep = now
+	for k, e := range s.records {
+		if now.After(e.expires) {
+			delete(s.records, k)
+		}
+	}
+}
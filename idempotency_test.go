@@ -0,0 +1,210 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ttlStore records the TTLs passed to the wrapped store.
+type ttlStore struct {
+	IdempotencyStore
+	beginTTL, completeTTL time.Duration
+}
+
+func (s *ttlStore) Begin(ctx stdcontext.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.beginTTL = ttl
+	return s.IdempotencyStore.Begin(ctx, key, fingerprint, ttl)
+}
+
+func (s *ttlStore) Complete(ctx stdcontext.Context, key, token string, rec *IdempotencyRecord, ttl time.Duration) error {
+	s.completeTTL = ttl
+	return s.IdempotencyStore.Complete(ctx, key, token, rec, ttl)
+}
+
+// failingStore is an IdempotencyStore whose backend is down.
+type failingStore struct{}
+
+func (failingStore) Begin(stdcontext.Context, string, string, time.Duration) (*IdempotencyRecord, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingStore) Complete(stdcontext.Context, string, string, *IdempotencyRecord, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) Release(stdcontext.Context, string, string) error {
+	return errors.New("connection refused")
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	store := &ttlStore{IdempotencyStore: NewMemoryIdempotencyStore()}
+	calls := 0
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("orders", func(g *Group) {
+		g.POST("", func(c Context) error {
+			calls++
+
+			h := c.Response().Header()
+			h.Set("Location", "/orders/1")
+			h.Set("Set-Cookie", "session=first")
+			h.Set("RateLimit-Remaining", "9")
+			h.Set("X-Request-ID", "first")
+
+			return c.String(http.StatusCreated, "created")
+		}, fakeAuth, Idempotency(IdempotencyConfig{Store: store, LockTTL: 5 * time.Second}))
+	})
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"item":1}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("X-Subject", "alice")
+
+		rec := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	first := post()
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d", first.Code)
+	}
+
+	if store.beginTTL != 5*time.Second || store.completeTTL != 24*time.Hour {
+		t.Fatalf("Begin TTL = %s, Complete TTL = %s, want 5s and 24h", store.beginTTL, store.completeTTL)
+	}
+
+	replay := post()
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+
+	if replay.Code != http.StatusCreated || replay.Body.String() != "created" {
+		t.Fatalf("replay = %d %q", replay.Code, replay.Body)
+	}
+
+	h := replay.Header()
+	if h.Get("Idempotent-Replayed") != "true" || h.Get("Location") != "/orders/1" {
+		t.Fatalf("replay headers = %v", h)
+	}
+
+	for _, k := range []string{"Set-Cookie", "RateLimit-Remaining", "X-Request-ID"} {
+		if v := h.Get(k); v != "" {
+			t.Errorf("replay carries %s: %q", k, v)
+		}
+	}
+}
+
+func TestLockTTL(t *testing.T) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), time.Hour)
+	defer cancel()
+
+	if got := lockTTL(stdcontext.Background(), time.Minute); got != time.Minute {
+		t.Errorf("without deadline = %s, want 1m", got)
+	}
+
+	if got := lockTTL(ctx, time.Minute); got < 59*time.Minute {
+		t.Errorf("with 1h deadline = %s, want about 1h", got)
+	}
+}
+
+func TestIdempotencyRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   IdempotencyStore
+		cfg     IdempotencyConfig
+		subject string
+		// wantCodes are the statuses of two identical requests.
+		wantCodes [2]int
+		wantCalls int
+	}{
+		{name: "replayed", subject: "alice", wantCodes: [2]int{http.StatusCreated, http.StatusCreated}, wantCalls: 1},
+		{name: "anonymous not stored", wantCodes: [2]int{http.StatusCreated, http.StatusCreated}, wantCalls: 2},
+		{name: "store down rejected", store: failingStore{}, subject: "alice", wantCodes: [2]int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}},
+		{name: "store down fail open", store: failingStore{}, cfg: IdempotencyConfig{FailOpen: true}, subject: "alice", wantCodes: [2]int{http.StatusCreated, http.StatusCreated}, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			cfg := tt.cfg
+			cfg.Store = tt.store
+
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("orders", func(g *Group) {
+				g.POST("", func(c Context) error {
+					calls++
+					return c.String(http.StatusCreated, "created")
+				}, fakeAuth, Idempotency(cfg))
+			})
+
+			for n, want := range tt.wantCodes {
+				req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"item":1}`))
+				req.Header.Set("Idempotency-Key", "k1")
+				if tt.subject != "" {
+					req.Header.Set("X-Subject", tt.subject)
+				}
+
+				rec := httptest.NewRecorder()
+				srv.Engine().ServeHTTP(rec, req)
+
+				if rec.Code != want {
+					t.Fatalf("request %d = %d, want %d", n, rec.Code, want)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMemoryIdempotencyStoreFencesExpiredReservations(t *testing.T) {
+	ctx := stdcontext.Background()
+	store := NewMemoryIdempotencyStore()
+
+	first, acquired, err := store.Begin(ctx, "k", "fp", time.Millisecond)
+	if err != nil || !acquired {
+		t.Fatalf("first Begin = %v, %v", acquired, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	second, acquired, err := store.Begin(ctx, "k", "fp", time.Minute)
+	if err != nil || !acquired || second.Token == first.Token {
+		t.Fatalf("second Begin after expiry = %+v, %v, %v", second, acquired, err)
+	}
+
+	// The first request outlived its reservation and must not touch the
+	// second one's record.
+	if err := store.Complete(ctx, "k", first.Token, &IdempotencyRecord{Fingerprint: "fp", Completed: true, StatusCode: http.StatusOK}, time.Hour); !errors.Is(err, ErrIdempotencyLockLost) {
+		t.Fatalf("stale Complete = %v, want ErrIdempotencyLockLost", err)
+	}
+
+	if err := store.Release(ctx, "k", first.Token); !errors.Is(err, ErrIdempotencyLockLost) {
+		t.Fatalf("stale Release = %v, want ErrIdempotencyLockLost", err)
+	}
+
+	if rec, acquired, _ := store.Begin(ctx, "k", "fp", time.Minute); acquired || rec.Completed {
+		t.Fatalf("record after stale calls = %+v, acquired %v, want the second reservation in flight", rec, acquired)
+	}
+
+	if err := store.Complete(ctx, "k", second.Token, &IdempotencyRecord{Fingerprint: "fp", Completed: true, StatusCode: http.StatusCreated}, time.Hour); err != nil {
+		t.Fatalf("Complete = %v", err)
+	}
+
+	if rec, _, _ := store.Begin(ctx, "k", "fp", time.Minute); !rec.Completed || rec.StatusCode != http.StatusCreated {
+		t.Fatalf("completed record = %+v", rec)
+	}
+
+	if err := store.Release(ctx, "k", second.Token); !errors.Is(err, ErrIdempotencyLockLost) {
+		t.Fatalf("Release of a completed record = %v, want ErrIdempotencyLockLost", err)
+	}
+}
@@ -0,0 +1,304 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksMinRefresh bounds how often an unknown key ID can force a JWKS reload,
+// so tokens with random kids cannot hammer the key endpoint.
+const jwksMinRefresh = 30 * time.Second
+
+// jwk is a single JSON Web Key as published in a JWKS document.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS decodes a JWKS document into keys indexed by kid. Keys not meant
+// for signatures and unsupported key types are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey converts the JWK into a key usable by the JWT verifier. Symmetric
+// keys are returned as []byte. Unsupported key types yield a nil key.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeB64Int(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeB64Int(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeB64Int(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeB64Int(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeB64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// jwksLoadTimeout bounds a JWKS reload, which runs detached from the
+// requests waiting for it.
+const jwksLoadTimeout = 10 * time.Second
+
+// jwksSource loads a JWKS document from a file or URL and caches the parsed
+// keys. The cache is refreshed every refresh interval and, at most once per
+// jwksMinRefresh, whenever a token names an unknown key ID. Cached keys are
+// served while a reload runs; concurrent reloads are coalesced. Failed
+// refreshes keep serving the previous keys.
+type jwksSource struct {
+	load    func(ctx stdcontext.Context) ([]byte, error)
+	refresh time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	loadedAt    time.Time
+	attemptedAt time.Time
+	lastErr     error
+	// reloading is closed when the running reload finishes, nil when none
+	// runs.
+	reloading chan struct{}
+}
+
+func newJWKSFileSource(path string, refresh time.Duration) *jwksSource {
+	return &jwksSource{
+		refresh: refresh,
+		load: func(stdcontext.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+	}
+}
+
+func newJWKSURLSource(url string, client *http.Client, refresh time.Duration) *jwksSource {
+	return &jwksSource{
+		refresh: refresh,
+		load: func(ctx stdcontext.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("jwks: %s returned %d", url, res.StatusCode)
+			}
+
+			return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		},
+	}
+}
+
+// key returns the key for kid. Known keys are served from the cache, which
+// is refreshed in the background once stale; unknown kids wait for a
+// reload.
+func (s *jwksSource) key(ctx stdcontext.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := lookupKey(s.keys, kid)
+	stale := time.Since(s.loadedAt) > s.refresh
+	s.mu.RUnlock()
+
+	if ok {
+		if stale {
+			s.startReload()
+		}
+
+		return key, nil
+	}
+
+	if done := s.startReload(); done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if key, ok := lookupKey(s.keys, kid); ok {
+		return key, nil
+	}
+
+	if s.keys == nil && s.lastErr != nil {
+		return nil, s.lastErr
+	}
+
+	return nil, fmt.Errorf("jwks: unknown key %q", kid)
+}
+
+// startReload joins the running reload or starts one, unless the last one
+// was attempted within jwksMinRefresh. It returns the channel closed when
+// the reload finishes, or nil when none runs.
+func (s *jwksSource) startReload() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reloading != nil {
+		return s.reloading
+	}
+
+	if time.Since(s.attemptedAt) <= jwksMinRefresh {
+		return nil
+	}
+
+	s.attemptedAt = time.Now()
+	s.reloading = make(chan struct{})
+	go s.reload(s.reloading)
+
+	return s.reloading
+}
+
+// lookupKey finds the key for kid. Tokens without a kid match the only key of
+// a single-key set.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// reload fetches and parses the document without holding s.mu, then swaps
+// in the keys and closes done.
+func (s *jwksSource) reload(done chan struct{}) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), jwksLoadTimeout)
+	defer cancel()
+
+	var keys map[string]crypto.PublicKey
+
+	data, err := s.load(ctx)
+	if err != nil {
+		err = fmt.Errorf("jwks: %w", err)
+	} else {
+		keys, err = parseJWKS(data)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.loadedAt = time.Now()
+	}
+
+	s.lastErr = err
+	s.reloading = nil
+	s.mu.Unlock()
+
+	close(done)
+}
@@ -0,0 +1,264 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksServer serves a JWKS document whose keys can be swapped during a test.
+type jwksServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	keys  map[string]*rsa.PrivateKey
+	delay chan struct{}
+	hits  int
+}
+
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{}
+	s.rotate(t, kids...)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// rotate replaces the published keys with fresh keys named kids.
+func (s *jwksServer) rotate(t *testing.T, kids ...string) {
+	t.Helper()
+
+	keys := map[string]*rsa.PrivateKey{}
+	for _, kid := range kids {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys[kid] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits++
+	delay := s.delay
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	for kid, key := range s.keys {
+		doc.Keys = append(doc.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	s.mu.Unlock()
+
+	if delay != nil {
+		select {
+		case <-delay:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (s *jwksServer) sign(t *testing.T, kid string) string {
+	t.Helper()
+
+	s.mu.Lock()
+	key := s.keys[kid]
+	s.mu.Unlock()
+
+	if key == nil {
+		var err error
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	tok.Header["kid"] = kid
+
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup runs after the first, successful request and returns the
+		// token of the second one.
+		setup      func(t *testing.T, s *jwksServer, src *jwksSource) string
+		wantStatus int
+	}{
+		{
+			name: "cached kid",
+			setup: func(t *testing.T, s *jwksServer, _ *jwksSource) string {
+				return s.sign(t, "k1")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "rotated kid",
+			setup: func(t *testing.T, s *jwksServer, src *jwksSource) string {
+				s.rotate(t, "k2")
+				allowReload(src)
+				return s.sign(t, "k2")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "unknown kid",
+			setup: func(t *testing.T, s *jwksServer, src *jwksSource) string {
+				allowReload(src)
+				return s.sign(t, "other")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "rotated kid within min refresh",
+			setup: func(t *testing.T, s *jwksServer, _ *jwksSource) string {
+				s.rotate(t, "k2")
+				return s.sign(t, "k2")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newJWKSServer(t, "k1")
+
+			cfg := JWTConfig{JWKSURL: s.URL}.withDefaults()
+			keys := newJWTKeys(cfg)
+			parser := jwt.NewParser(jwtParserOptions(cfg)...)
+
+			verify := func(raw string) int {
+				_, err := parser.Parse(raw, func(tok *jwt.Token) (any, error) {
+					kid, _ := tok.Header["kid"].(string)
+					return keys.key(stdcontext.Background(), kid)
+				})
+				if err != nil {
+					return http.StatusUnauthorized
+				}
+
+				return http.StatusOK
+			}
+
+			if code := verify(s.sign(t, "k1")); code != http.StatusOK {
+				t.Fatalf("first request: status = %d, want 200", code)
+			}
+
+			if code := verify(tt.setup(t, s, keys.jwks)); code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestJWKSSourceServesCachedKeyWhileReloading(t *testing.T) {
+	s := newJWKSServer(t, "k1")
+	src := newJWKSURLSource(s.URL, http.DefaultClient, time.Hour)
+
+	if _, err := src.key(stdcontext.Background(), "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stall the endpoint and expire the cache.
+	delay := make(chan struct{})
+	s.mu.Lock()
+	s.delay = delay
+	s.mu.Unlock()
+	defer close(delay)
+
+	src.mu.Lock()
+	src.loadedAt = time.Time{}
+	src.mu.Unlock()
+	allowReload(src)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := src.key(stdcontext.Background(), "k1")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cached key blocked on a slow JWKS reload")
+	}
+
+	// An unknown kid waits for the reload, bounded by its context.
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := src.key(ctx, "k2"); err == nil {
+		t.Fatal("unknown kid resolved while the reload was stalled")
+	}
+}
+
+func TestJWKSSourceCoalescesReloads(t *testing.T) {
+	s := newJWKSServer(t, "k1")
+	src := newJWKSURLSource(s.URL, http.DefaultClient, time.Hour)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = src.key(stdcontext.Background(), "k1")
+		}()
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hits != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", s.hits)
+	}
+}
+
+// allowReload lets the next unknown kid reload the JWKS, as if
+// jwksMinRefresh had passed.
+func allowReload(src *jwksSource) {
+	src.mu.Lock()
+	src.attemptedAt = time.Time{}
+	src.mu.Unlock()
+}
@@ -0,0 +1,215 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"crypto"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// ClaimsKey is the context key holding the validated token Claims.
+	ClaimsKey = "claims"
+	// ScopesKey is the context key holding the granted scopes as []string.
+	ScopesKey = "scopes"
+)
+
+var (
+	errMissingToken = newHTTPError(http.StatusUnauthorized, "missing bearer token")
+	errInvalidToken = newHTTPError(http.StatusUnauthorized, "invalid or expired token")
+)
+
+// Claims are the validated claims of a JWT.
+type Claims map[string]any
+
+// String returns the claim as a string, or "" when absent or not a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim as a string list. Space-separated strings (as
+// used by the "scope" claim) are split.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+
+		return out
+	default:
+		return nil
+	}
+}
+
+// JWTConfig configures the JWT authentication middleware. At least one key
+// source (Secret, Keys, JWKSFile or JWKSURL) is required.
+type JWTConfig struct {
+	// Algorithms lists the accepted signing algorithms. Defaults to HS256,
+	// RS256, ES256 and EdDSA.
+	Algorithms []string
+	// Issuer, when set, must match the "iss" claim.
+	Issuer string
+	// Audience, when set, requires the "aud" claim to contain one of the
+	// listed values.
+	Audience []string
+	// ClockSkew is the leeway applied to "exp", "nbf" and "iat".
+	ClockSkew time.Duration
+	// Secret is a static HMAC key.
+	Secret []byte
+	// Keys are static verification keys indexed by key ID. Use an empty key
+	// ID for tokens without a "kid" header.
+	Keys map[string]crypto.PublicKey
+	// JWKSFile loads keys from a JWKS document on disk.
+	JWKSFile string
+	// JWKSURL loads keys from a JWKS endpoint.
+	JWKSURL string
+	// JWKSRefresh is how long a loaded JWKS is cached. Unknown key IDs force
+	// an earlier reload, at most every 30s. Defaults to one hour.
+	JWKSRefresh time.Duration
+	// HTTPClient fetches JWKSURL. Defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+	// ScopeClaim names the claim listing granted scopes. Defaults to
+	// "scope".
+	ScopeClaim string
+	// RoleClaim names the claim listing the principal's roles. Defaults to
+	// "roles".
+	RoleClaim string
+}
+
+func (c JWTConfig) withDefaults() JWTConfig {
+	if len(c.Algorithms) == 0 {
+		c.Algorithms = []string{"HS256", "RS256", "ES256", "EdDSA"}
+	}
+
+	if c.JWKSRefresh <= 0 {
+		c.JWKSRefresh = time.Hour
+	}
+
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if c.ScopeClaim == "" {
+		c.ScopeClaim = "scope"
+	}
+
+	if c.RoleClaim == "" {
+		c.RoleClaim = "roles"
+	}
+
+	return c
+}
+
+// JWT returns a middleware that authenticates requests carrying an
+// "Authorization: Bearer <token>" header. Valid tokens expose their claims
+// through Context.Claims, Context.Subject, Context.HasScope and
+// Context.HasRole; missing or invalid tokens fail with 401. It panics when no
+// key source is configured.
+func JWT(cfg JWTConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	keys := newJWTKeys(cfg)
+
+	parser := jwt.NewParser(jwtParserOptions(cfg)...)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			raw, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if !ok || raw == "" {
+				c.Response().Header().Set("WWW-Authenticate", "Bearer")
+				return errMissingToken
+			}
+
+			claims := jwt.MapClaims{}
+			_, err := parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+				kid, _ := t.Header["kid"].(string)
+				return keys.key(c.Request().Context(), kid)
+			})
+			if err != nil {
+				c.Logger().Debugf("jwt: %v", err)
+				c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				return errInvalidToken
+			}
+
+			cl := Claims(claims)
+			c.Set(ClaimsKey, cl)
+			c.Set(SubjectKey, cl.String("sub"))
+			c.Set(ScopesKey, cl.Strings(cfg.ScopeClaim))
+			c.Set(RolesKey, cl.Strings(cfg.RoleClaim))
+
+			return next(c)
+		}
+	}
+}
+
+func jwtParserOptions(cfg JWTConfig) []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(cfg.Algorithms),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	if len(cfg.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(cfg.Audience...))
+	}
+
+	return opts
+}
+
+// jwtKeys resolves verification keys from the static configuration first and
+// then from the JWKS source, if any.
+type jwtKeys struct {
+	static map[string]crypto.PublicKey
+	jwks   *jwksSource
+}
+
+func newJWTKeys(cfg JWTConfig) *jwtKeys {
+	k := &jwtKeys{static: map[string]crypto.PublicKey{}}
+	for kid, key := range cfg.Keys {
+		k.static[kid] = key
+	}
+
+	if len(cfg.Secret) > 0 {
+		k.static[""] = cfg.Secret
+	}
+
+	switch {
+	case cfg.JWKSURL != "":
+		k.jwks = newJWKSURLSource(cfg.JWKSURL, cfg.HTTPClient, cfg.JWKSRefresh)
+	case cfg.JWKSFile != "":
+		k.jwks = newJWKSFileSource(cfg.JWKSFile, cfg.JWKSRefresh)
+	}
+
+	if len(k.static) == 0 && k.jwks == nil {
+		panic("echoext: JWT requires Secret, Keys, JWKSFile or JWKSURL")
+	}
+
+	return k
+}
+
+func (k *jwtKeys) key(ctx stdcontext.Context, kid string) (any, error) {
+	if key, ok := lookupKey(k.static, kid); ok {
+		return key, nil
+	}
+
+	if k.jwks == nil {
+		return nil, errors.New("unknown key")
+	}
+
+	return k.jwks.key(ctx, kid)
+}
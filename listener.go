@@ -0,0 +1,176 @@
+package echoext
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// systemdFirstFD is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START).
+const systemdFirstFD = 3
+
+// ListenerConfig selects where the main server listens. At most one of
+// Listener, UnixSocket and Systemd should be set; without any, the server
+// listens on TCP at Host and Port.
+type ListenerConfig struct {
+	// Listener is a pre-opened listener, such as one created by a test.
+	Listener net.Listener
+	// UnixSocket is the path of a Unix domain socket to listen on. A stale
+	// socket file at that path is replaced.
+	UnixSocket string
+	// UnixSocketMode sets the socket file's permissions. Defaults to 0660.
+	UnixSocketMode fs.FileMode
+	// Systemd uses a socket passed by systemd socket activation (LISTEN_FDS).
+	Systemd bool
+	// SystemdName picks the socket whose FileDescriptorName matches when
+	// several are passed. Defaults to the first socket not used by another
+	// server.
+	SystemdName string
+}
+
+// listen opens the listener described by c, falling back to TCP on addr.
+func (c *ListenerConfig) listen(addr string) (net.Listener, error) {
+	switch {
+	case c.Listener != nil:
+		return c.Listener, nil
+	case c.Systemd:
+		return systemdListener(c.SystemdName)
+	case c.UnixSocket != "":
+		return c.listenUnix()
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	return ln, nil
+}
+
+func (c *ListenerConfig) listenUnix() (net.Listener, error) {
+	if fi, err := os.Lstat(c.UnixSocket); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen: %s exists and is not a socket", c.UnixSocket)
+		}
+
+		if err := os.Remove(c.UnixSocket); err != nil {
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", c.UnixSocket)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	mode := c.UnixSocketMode
+	if mode == 0 {
+		mode = 0o660
+	}
+
+	if err := os.Chmod(c.UnixSocket, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	return ln, nil
+}
+
+// systemd holds the sockets passed to this process by systemd.
+var systemd = &systemdSockets{load: systemdFiles}
+
+// systemdListener returns a listener on the socket passed by systemd named
+// name, or on the first socket not yet used when name is empty.
+func systemdListener(name string) (net.Listener, error) {
+	return systemd.listener(name)
+}
+
+// systemdSockets reads the LISTEN_* variables once and keeps the passed
+// sockets, so every server with Systemd set can find its own.
+type systemdSockets struct {
+	load func() ([]*os.File, error)
+
+	once  sync.Once
+	files []*os.File
+	err   error
+
+	mu   sync.Mutex
+	used []bool
+}
+
+func (s *systemdSockets) listener(name string) (net.Listener, error) {
+	s.once.Do(func() {
+		s.files, s.err = s.load()
+		s.used = make([]bool, len(s.files))
+	})
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.files {
+		if name != "" && f.Name() != name || name == "" && s.used[i] {
+			continue
+		}
+
+		// FileListener dups the descriptor, so the socket can be listened
+		// on again, for example after a restart within the process.
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("listen: systemd socket %d: %w", i, err)
+		}
+
+		s.used[i] = true
+
+		return ln, nil
+	}
+
+	if name == "" {
+		return nil, errors.New("listen: every socket passed by systemd is in use")
+	}
+
+	return nil, fmt.Errorf("listen: no systemd socket named %q", name)
+}
+
+// systemdFiles returns the sockets passed by systemd, named after
+// LISTEN_FDNAMES. The LISTEN_* variables are cleared so child processes do
+// not pick the sockets up again.
+func systemdFiles() ([]*os.File, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("listen: no sockets passed by systemd")
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("listen: no sockets passed by systemd")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make([]*os.File, n)
+	for i := range files {
+		var fdName string
+		if i < len(names) {
+			fdName = names[i]
+		}
+
+		files[i] = os.NewFile(uintptr(systemdFirstFD+i), fdName)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,124 @@
+package echoext
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// tcpSocketFile opens a loopback listener and returns a named file for its
+// socket, as passed by systemd, and its address.
+func tcpSocketFile(t *testing.T, name string) (*os.File, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	named := os.NewFile(uintptr(fd), name)
+	t.Cleanup(func() { named.Close() })
+
+	return named, ln.Addr().String()
+}
+
+func TestSystemdSockets(t *testing.T) {
+	tests := []struct {
+		name string
+		// lookups are the SystemdName of each server, in order.
+		lookups []string
+		// want is the socket each lookup gets, by name, or "" for an error.
+		want []string
+	}{
+		{name: "first by default", lookups: []string{""}, want: []string{"http"}},
+		{name: "by name", lookups: []string{"admin"}, want: []string{"admin"}},
+		{name: "two servers by name", lookups: []string{"http", "admin"}, want: []string{"http", "admin"}},
+		{name: "unnamed servers take unused sockets", lookups: []string{"http", "", ""}, want: []string{"http", "admin", ""}},
+		{name: "unknown name", lookups: []string{"grpc"}, want: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpFile, httpAddr := tcpSocketFile(t, "http")
+			adminFile, adminAddr := tcpSocketFile(t, "admin")
+			addrs := map[string]string{"http": httpAddr, "admin": adminAddr}
+
+			loads := 0
+			s := &systemdSockets{load: func() ([]*os.File, error) {
+				loads++
+				return []*os.File{httpFile, adminFile}, nil
+			}}
+
+			for i, name := range tt.lookups {
+				ln, err := s.listener(name)
+				if tt.want[i] == "" {
+					if err == nil {
+						ln.Close()
+						t.Fatalf("lookup %q: got %s, want error", name, ln.Addr())
+					}
+
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("lookup %q: %v", name, err)
+				}
+
+				if got := ln.Addr().String(); got != addrs[tt.want[i]] {
+					t.Errorf("lookup %q: listening on %s, want socket %q at %s", name, got, tt.want[i], addrs[tt.want[i]])
+				}
+
+				ln.Close()
+			}
+
+			if loads != 1 {
+				t.Fatalf("LISTEN_* read %d times, want 1", loads)
+			}
+		})
+	}
+}
+
+func TestSystemdSocketsLoadError(t *testing.T) {
+	s := &systemdSockets{load: func() ([]*os.File, error) {
+		return nil, errors.New("listen: no sockets passed by systemd")
+	}}
+
+	for range 2 {
+		if _, err := s.listener(""); err == nil {
+			t.Fatal("listener succeeded without sockets")
+		}
+	}
+}
+
+func TestListenerConfigInjected(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c := ListenerConfig{Listener: ln}
+
+	got, err := c.listen("0.0.0.0:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != ln {
+		t.Fatal("listen ignored the injected listener")
+	}
+}
@@ -0,0 +1,611 @@
+package echoext
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	validator "github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// LoaderConfig configures LoadConfig.
+type LoaderConfig struct {
+	// Files are read in order, later files overriding earlier ones. The
+	// format is picked by extension: .yaml, .yml, .json or .toml.
+	Files []string
+	// EnvPrefix namespaces environment variables: with "APP", the key
+	// metrics.port is read from APP_METRICS_PORT. Without it, environment
+	// variables are not read, since bare names such as HOST are commonly set
+	// by shells.
+	EnvPrefix string
+	// Args are the command-line arguments parsed as flags, usually
+	// os.Args[1:]. Flags are only parsed when Args is set, so programs and
+	// test binaries with flags of their own are left alone.
+	Args []string
+}
+
+// ConfigProblem is one invalid configuration key.
+type ConfigProblem struct {
+	// Key is the dotted configuration key, such as metrics.port.
+	Key string
+	// Source is where the value came from: "default", a file path, an
+	// environment variable, a flag or "validation".
+	Source string
+	Err    error
+}
+
+func (p ConfigProblem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("%s: %v", p.Source, p.Err)
+	}
+
+	return fmt.Sprintf("%s (%s): %v", p.Key, p.Source, p.Err)
+}
+
+// ConfigError reports every problem LoadConfig found.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("config: %d invalid key(s)", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// LoadConfig fills dst, a pointer to ServerConfig or to an application
+// config embedding it, from these sources in increasing precedence:
+//
+//   - `default:"..."` struct tags, for fields that are still zero
+//   - the files in cfg.Files
+//   - environment variables, when cfg.EnvPrefix is set
+//   - command-line flags, when cfg.Args is set
+//
+// Keys default to the snake_case field name and can be renamed with a
+// `config:"name"` tag; `config:"-"` skips a field. Nested structs form
+// dotted keys (metrics.port), embedded structs are flattened. Environment
+// variables use the upper-case key with dots replaced by underscores
+// (METRICS_PORT) and flags the key with dashes (--metrics.port). Slices
+// are comma-separated outside files.
+//
+// The result is checked against `validate` tags. Every unknown key or flag,
+// value that does not parse and failed validation is reported in one
+// *ConfigError. flag.ErrHelp is returned as is when -h is passed.
+func LoadConfig(dst any, cfg ...LoaderConfig) error {
+	c := LoaderConfig{}
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: LoadConfig needs a non-nil pointer to a struct")
+	}
+
+	l := &configLoader{}
+	l.collect(rv.Elem(), "", "")
+
+	for _, f := range l.fields {
+		if def, ok := f.tag.Lookup("default"); ok && f.value.IsZero() {
+			l.set(f, def, "default")
+		}
+	}
+
+	for _, path := range c.Files {
+		l.loadFile(rv.Elem(), path)
+	}
+
+	if c.EnvPrefix != "" {
+		for _, f := range l.fields {
+			name := envName(c.EnvPrefix, f.key)
+			if v, ok := os.LookupEnv(name); ok {
+				l.set(f, v, name)
+			}
+		}
+	}
+
+	if c.Args != nil {
+		if err := l.parseFlags(c.Args, c.EnvPrefix); err != nil {
+			return err
+		}
+	}
+
+	l.validate(dst)
+
+	if len(l.problems) > 0 {
+		return &ConfigError{Problems: l.problems}
+	}
+
+	return nil
+}
+
+// configField is a settable leaf of the configuration.
+type configField struct {
+	key    string
+	goPath string
+	value  reflect.Value
+	tag    reflect.StructTag
+}
+
+type configLoader struct {
+	fields   []configField
+	problems []ConfigProblem
+	// keys maps validator namespaces to configuration keys.
+	keys map[string]string
+}
+
+func (l *configLoader) problem(key, source string, err error) {
+	l.problems = append(l.problems, ConfigProblem{Key: key, Source: source, Err: err})
+}
+
+// structField is a configuration field of a struct type, with embedded
+// structs flattened.
+type structField struct {
+	key    string
+	goPath string
+	index  []int
+	tag    reflect.StructTag
+}
+
+func structFields(t reflect.Type) []structField {
+	var out []structField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := sf.Tag.Lookup("config")
+		if name == "-" || !sf.IsExported() {
+			continue
+		}
+
+		if sf.Anonymous && !ok && sf.Type.Kind() == reflect.Struct && !isLeaf(sf.Type) {
+			for _, inner := range structFields(sf.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				inner.goPath = sf.Name + "." + inner.goPath
+				out = append(out, inner)
+			}
+
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(sf.Name)
+		}
+
+		out = append(out, structField{key: name, goPath: sf.Name, index: []int{i}, tag: sf.Tag})
+	}
+
+	return out
+}
+
+// collect records the leaves of v under the key and Go path prefixes.
+func (l *configLoader) collect(v reflect.Value, keyPrefix, goPrefix string) {
+	if l.keys == nil {
+		l.keys = map[string]string{}
+	}
+
+	for _, sf := range structFields(v.Type()) {
+		fv := v.FieldByIndex(sf.index)
+		key, goPath := keyPrefix+sf.key, goPrefix+sf.goPath
+
+		switch {
+		case isLeaf(fv.Type()):
+			l.fields = append(l.fields, configField{key: key, goPath: goPath, value: fv, tag: sf.tag})
+			l.keys[goPath] = key
+		case fv.Kind() == reflect.Struct:
+			l.collect(fv, key+".", goPath+".")
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			for i := 0; i < fv.Len(); i++ {
+				l.collectElem(fv.Index(i), fmt.Sprintf("%s[%d]", key, i), fmt.Sprintf("%s[%d]", goPath, i))
+			}
+		}
+	}
+}
+
+// collectElem maps a slice element's keys for validation only; elements
+// are set from files alone.
+func (l *configLoader) collectElem(v reflect.Value, key, goPath string) {
+	sub := &configLoader{keys: l.keys}
+	sub.collect(v, key+".", goPath+".")
+}
+
+// isLeaf reports whether values of t are parsed from a single string.
+func isLeaf(t reflect.Type) bool {
+	if t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && isLeaf(t.Elem())
+	}
+
+	return false
+}
+
+func (l *configLoader) set(f configField, s, source string) {
+	if err := setString(f.value, s); err != nil {
+		l.problem(f.key, source, err)
+	}
+}
+
+// setString parses s into v.
+func setString(v reflect.Value, s string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+
+		var parts []string
+		if strings.TrimSpace(s) != "" {
+			parts = strings.Split(s, ",")
+		}
+
+		return setList(v, parts)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func setList(v reflect.Value, items []string) error {
+	out := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := setString(out.Index(i), strings.TrimSpace(item)); err != nil {
+			return err
+		}
+	}
+
+	v.Set(out)
+
+	return nil
+}
+
+// loadFile decodes the file at path and applies it to v.
+func (l *configLoader) loadFile(v reflect.Value, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.problem("", path, err)
+		return
+	}
+
+	m := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &m)
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		err = d.Decode(&m)
+	case ".toml":
+		err = toml.Unmarshal(data, &m)
+	default:
+		err = errors.New("unsupported config file format")
+	}
+
+	if err != nil {
+		l.problem("", path, err)
+		return
+	}
+
+	l.applyMap(v, m, "", "", path)
+}
+
+// applyMap sets the fields of struct v from the decoded file section m.
+func (l *configLoader) applyMap(v reflect.Value, m map[string]any, keyPrefix, goPrefix, source string) {
+	fields := map[string]structField{}
+	for _, sf := range structFields(v.Type()) {
+		fields[normalizeKey(sf.key)] = sf
+	}
+
+	for name, raw := range m {
+		key := keyPrefix + name
+		sf, ok := fields[normalizeKey(name)]
+		if !ok {
+			l.problem(key, source, errors.New("unknown key"))
+			continue
+		}
+
+		key, goPath := keyPrefix+sf.key, goPrefix+sf.goPath
+		fv := v.FieldByIndex(sf.index)
+		l.keys[goPath] = key
+
+		if err := l.applyValue(fv, raw, key, goPath, source); err != nil {
+			l.problem(key, source, err)
+		}
+	}
+}
+
+func (l *configLoader) applyValue(v reflect.Value, raw any, key, goPath, source string) error {
+	switch {
+	case isLeaf(v.Type()) && v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		items, ok := raw.([]any)
+		if !ok {
+			return setString(v, scalarString(raw))
+		}
+
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = scalarString(item)
+		}
+
+		return setList(v, parts)
+	case isLeaf(v.Type()):
+		if _, ok := raw.(map[string]any); ok {
+			return errors.New("expected a value, got a section")
+		}
+
+		return setString(v, scalarString(raw))
+	case v.Kind() == reflect.Struct:
+		section, ok := raw.(map[string]any)
+		if !ok {
+			return errors.New("expected a section")
+		}
+
+		l.applyMap(v, section, key+".", goPath+".", source)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		var sections []map[string]any
+		switch items := raw.(type) {
+		case []map[string]any:
+			sections = items
+		case []any:
+			for _, item := range items {
+				section, ok := item.(map[string]any)
+				if !ok {
+					return errors.New("expected a list of sections")
+				}
+
+				sections = append(sections, section)
+			}
+		default:
+			return errors.New("expected a list of sections")
+		}
+
+		out := reflect.MakeSlice(v.Type(), len(sections), len(sections))
+		for i, section := range sections {
+			l.applyMap(out.Index(i), section, fmt.Sprintf("%s[%d].", key, i), fmt.Sprintf("%s[%d].", goPath, i), source)
+		}
+
+		v.Set(out)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// scalarString formats a decoded file value the way setString parses it.
+func scalarString(raw any) string {
+	switch x := raw.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(raw)
+}
+
+// parseFlags applies the flags in args, one per leaf key, as --key=value,
+// --key value or --key for booleans. Parsing stops at "--" or the first
+// argument that is not a flag. Unlike flag.FlagSet, every unknown flag and
+// missing value is reported, not only the first.
+func (l *configLoader) parseFlags(args []string, envPrefix string) error {
+	fields := make(map[string]configField, len(l.fields))
+	for _, f := range l.fields {
+		fields[flagName(f.key)] = f
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		if name == "h" || name == "help" {
+			l.usage(envPrefix)
+			return flag.ErrHelp
+		}
+
+		f, ok := fields[name]
+		switch {
+		case !ok:
+			l.problem("", "flags", fmt.Errorf("flag provided but not defined: -%s", name))
+			continue
+		case hasValue:
+		case f.value.Kind() == reflect.Bool:
+			value = "true"
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			l.problem(f.key, "--"+name, errors.New("flag needs an argument"))
+			continue
+		}
+
+		l.set(f, value, "--"+name)
+	}
+
+	return nil
+}
+
+// usage prints the flags to stderr in the flag package's format.
+func (l *configLoader) usage(envPrefix string) {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	for _, f := range l.fields {
+		usage := f.tag.Get("usage")
+		switch {
+		case usage != "":
+		case envPrefix != "":
+			usage = "see " + envName(envPrefix, f.key)
+		default:
+			usage = "sets " + f.key
+		}
+
+		noop := func(string) error { return nil }
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(flagName(f.key), usage, noop)
+		} else {
+			fs.Func(flagName(f.key), usage, noop)
+		}
+	}
+
+	fs.PrintDefaults()
+}
+
+// validate checks dst against its validate tags.
+func (l *configLoader) validate(dst any) {
+	err := newValidator().Validate(dst)
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		if err != nil {
+			l.problem("", "validation", err)
+		}
+
+		return
+	}
+
+	for _, fe := range verrs {
+		ns := fe.StructNamespace()
+		if i := strings.IndexByte(ns, '.'); i >= 0 {
+			ns = ns[i+1:]
+		}
+
+		key, ok := l.keys[ns]
+		if !ok {
+			key = ns
+		}
+
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+
+		l.problem(key, "validation", fmt.Errorf("failed %q", rule))
+	}
+}
+
+func envName(prefix, key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if prefix = strings.TrimSuffix(prefix, "_"); prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+
+	return name
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+}
+
+// snakeCase converts a Go field name to a key: TLSCertFile becomes
+// tls_cert_file and AllowedCIDRs allowed_cidrs.
+func snakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]) &&
+				!pluralAcronym(runes[i+1:])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// pluralAcronym reports whether rest, following an upper-case letter, is the
+// "s" of a plural acronym such as the one in CIDRs.
+func pluralAcronym(rest []rune) bool {
+	return rest[0] == 's' && (len(rest) == 1 || unicode.IsUpper(rest[1]))
+}
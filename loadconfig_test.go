@@ -0,0 +1,215 @@
+package echoext
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type loaderTestConfig struct {
+	Name  string `default:"app"`
+	Debug bool
+	DB    struct {
+		URL     string        `validate:"required"`
+		Pool    int           `default:"10" validate:"gt=0"`
+		Timeout time.Duration `default:"1s"`
+	} `config:"db"`
+	Tags []string
+}
+
+// writeConfig writes content to a file named name in a temporary directory
+// and returns its path.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigEnvPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		env       map[string]string
+		wantHost  string
+		wantPort  int
+		wantError bool
+	}{
+		{name: "bare names ignored without prefix", env: map[string]string{"HOST": "shell-host", "PORT": "1"}, wantPort: 8080},
+		{name: "prefixed names read", prefix: "APP", env: map[string]string{"APP_HOST": "127.0.0.1", "APP_PORT": "9000", "HOST": "shell-host"}, wantHost: "127.0.0.1", wantPort: 9000},
+		{name: "prefixed invalid value", prefix: "APP", env: map[string]string{"APP_PORT": "http"}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := ServerConfig{Port: 8080}
+			err := LoadConfig(&cfg, LoaderConfig{EnvPrefix: tt.prefix})
+			if (err != nil) != tt.wantError {
+				t.Fatalf("LoadConfig() = %v, want error %v", err, tt.wantError)
+			}
+
+			if tt.wantError {
+				return
+			}
+
+			if cfg.Host != tt.wantHost || cfg.Port != tt.wantPort {
+				t.Fatalf("host, port = %q, %d, want %q, %d", cfg.Host, cfg.Port, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+	}{
+		{name: "yaml", file: "config.yaml", content: "name: svc\ndb:\n  url: postgres://db\n  pool: 5\ntags: [a, b]\n"},
+		{name: "yml", file: "config.yml", content: "name: svc\ndb: {url: postgres://db, pool: 5}\ntags: [a, b]\n"},
+		{name: "json", file: "config.json", content: `{"name": "svc", "db": {"url": "postgres://db", "pool": 5}, "tags": ["a", "b"]}`},
+		{name: "toml", file: "config.toml", content: "name = \"svc\"\ntags = [\"a\", \"b\"]\n\n[db]\nurl = \"postgres://db\"\npool = 5\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg loaderTestConfig
+			if err := LoadConfig(&cfg, LoaderConfig{Files: []string{writeConfig(t, tt.file, tt.content)}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Name != "svc" || cfg.DB.URL != "postgres://db" || cfg.DB.Pool != 5 || !slices.Equal(cfg.Tags, []string{"a", "b"}) {
+				t.Fatalf("config = %+v", cfg)
+			}
+
+			if cfg.DB.Timeout != time.Second {
+				t.Fatalf("db.timeout = %s, want the 1s default", cfg.DB.Timeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset int
+		files  []string
+		env    string
+		args   []string
+		want   int
+	}{
+		{name: "default", want: 10},
+		{name: "default keeps a preset value", preset: 7, want: 7},
+		{name: "file over default", files: []string{"db: {pool: 20}"}, want: 20},
+		{name: "later file over earlier", files: []string{"db: {pool: 20}", "db: {pool: 25}"}, want: 25},
+		{name: "env over file", files: []string{"db: {pool: 20}"}, env: "30", want: 30},
+		{name: "flag over env", files: []string{"db: {pool: 20}"}, env: "30", args: []string{"--db.pool=40"}, want: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("APP_DB_POOL", tt.env)
+			}
+
+			var files []string
+			for i, content := range tt.files {
+				files = append(files, writeConfig(t, "config"+strconv.Itoa(i)+".yaml", content))
+			}
+
+			var cfg loaderTestConfig
+			cfg.DB.URL = "postgres://db"
+			cfg.DB.Pool = tt.preset
+
+			if err := LoadConfig(&cfg, LoaderConfig{Files: files, EnvPrefix: "APP", Args: tt.args}); err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.DB.Pool != tt.want {
+				t.Fatalf("db.pool = %d, want %d", cfg.DB.Pool, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantPool  int
+		wantDebug bool
+	}{
+		{name: "no args", wantPool: 10},
+		{name: "equals", args: []string{"--db.pool=3"}, wantPool: 3},
+		{name: "separate value", args: []string{"--db.pool", "3"}, wantPool: 3},
+		{name: "single dash", args: []string{"-db.pool=3"}, wantPool: 3},
+		{name: "boolean", args: []string{"--debug", "--db.pool=3"}, wantPool: 3, wantDebug: true},
+		{name: "explicit boolean", args: []string{"--debug=false"}, wantPool: 10},
+		{name: "stops at an argument", args: []string{"--db.pool=3", "serve", "--db.pool=4"}, wantPool: 3},
+		{name: "stops at double dash", args: []string{"--", "--db.pool=4"}, wantPool: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg loaderTestConfig
+			cfg.DB.URL = "postgres://db"
+
+			if err := LoadConfig(&cfg, LoaderConfig{Args: tt.args}); err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.DB.Pool != tt.wantPool || cfg.Debug != tt.wantDebug {
+				t.Fatalf("db.pool, debug = %d, %v, want %d, %v", cfg.DB.Pool, cfg.Debug, tt.wantPool, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "unknown: 1\ndb:\n  pool: ten\n")
+	t.Setenv("APP_DB_TIMEOUT", "soon")
+
+	var cfg loaderTestConfig
+	err := LoadConfig(&cfg, LoaderConfig{
+		Files:     []string{path},
+		EnvPrefix: "APP",
+		Args:      []string{"--nope", "--also-nope=1", "--db.pool=zero", "--name"},
+	})
+
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("LoadConfig() = %v, want *ConfigError", err)
+	}
+
+	var got []string
+	for _, p := range cerr.Problems {
+		got = append(got, p.Key+" ("+p.Source+")")
+	}
+
+	want := []string{
+		"unknown (" + path + ")",
+		"db.pool (" + path + ")",
+		"db.timeout (APP_DB_TIMEOUT)",
+		" (flags)",
+		" (flags)",
+		"db.pool (--db.pool)",
+		"name (--name)",
+		"db.url (validation)",
+	}
+
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("problems = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,472 @@
+package echoext
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// LogConfig controls changing the log level at runtime and temporary debug
+// rules.
+type LogConfig struct {
+	// ToggleSignal switches the log level to debug and back to the level in
+	// effect before, while EnableAdmin is set. Defaults to SIGUSR1, which
+	// must then not be the signal of ReloadConfig or RestartConfig.
+	ToggleSignal os.Signal
+	// EnableAdmin handles ToggleSignal and serves /admin/log-level and
+	// /admin/debug-rules on the metrics server. The endpoints are only
+	// mounted when MetricsConfig sets Username, BearerToken or AllowedCIDRs.
+	EnableAdmin bool
+	// MaxBodyBytes caps the request and response bytes logged for traffic
+	// matching a debug rule. Defaults to 64 KiB.
+	MaxBodyBytes int `validate:"gte=0"`
+}
+
+func (c *LogConfig) toggleSignal() os.Signal {
+	if c.ToggleSignal == nil {
+		return syscall.SIGUSR1
+	}
+
+	return c.ToggleSignal
+}
+
+func (c *LogConfig) maxBodyBytes() int {
+	if c.MaxBodyBytes <= 0 {
+		return 64 << 10
+	}
+
+	return c.MaxBodyBytes
+}
+
+// DebugRule selects traffic whose request and response bodies are logged
+// until Expires. Empty fields match anything, but at least one must be set.
+type DebugRule struct {
+	ID string `json:"id"`
+	// Route is the templated route, such as /api/users/:id, or the request
+	// path.
+	Route  string `json:"route,omitempty"`
+	Method string `json:"method,omitempty"`
+	// IP is the client IP as resolved by ProxyConfig.
+	IP string `json:"ip,omitempty"`
+	// RequestID is matched against the X-Request-ID request and response
+	// headers. The package does not generate request IDs, so it only matches
+	// IDs set by the client, a proxy or an application middleware.
+	RequestID string    `json:"request_id,omitempty"`
+	Expires   time.Time `json:"expires"`
+}
+
+// matches reports whether the request in c is selected by r.
+func (r DebugRule) matches(c echo.Context) bool {
+	req := c.Request()
+
+	if r.Route != "" && r.Route != c.Path() && r.Route != req.URL.Path {
+		return false
+	}
+
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+
+	if r.IP != "" && r.IP != c.RealIP() {
+		return false
+	}
+
+	if r.RequestID != "" && r.RequestID != req.Header.Get(echo.HeaderXRequestID) && r.RequestID != c.Response().Header().Get(echo.HeaderXRequestID) {
+		return false
+	}
+
+	return true
+}
+
+// debugRules holds the active debug rules of a server and its named servers.
+type debugRules struct {
+	mu    sync.Mutex
+	rules []DebugRule
+}
+
+// maxDebugRuleDuration bounds how long a debug rule stays active.
+const maxDebugRuleDuration = time.Hour
+
+// add activates r for d and returns it with its ID and expiry set.
+func (rs *debugRules) add(r DebugRule, d time.Duration) (DebugRule, error) {
+	if d <= 0 || d > maxDebugRuleDuration {
+		return DebugRule{}, fmt.Errorf("debug rule: duration must be positive and at most %v", maxDebugRuleDuration)
+	}
+
+	if r.Route == "" && r.Method == "" && r.IP == "" && r.RequestID == "" {
+		return DebugRule{}, fmt.Errorf("debug rule: set at least one of route, method, ip or request_id")
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	r.ID = hex.EncodeToString(id)
+	r.Method = strings.ToUpper(r.Method)
+	r.Expires = time.Now().Add(d)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.rules = append(rs.rules, r)
+
+	return r, nil
+}
+
+// list returns the rules that have not expired, dropping the others.
+func (rs *debugRules) list() []DebugRule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.rules = slices.DeleteFunc(rs.rules, func(r DebugRule) bool { return !now.Before(r.Expires) })
+
+	return slices.Clone(rs.rules)
+}
+
+// remove deletes the rule with id, or every rule when id is empty, and
+// reports whether any was removed.
+func (rs *debugRules) remove(id string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	n := len(rs.rules)
+	rs.rules = slices.DeleteFunc(rs.rules, func(r DebugRule) bool { return id == "" || r.ID == id })
+
+	return len(rs.rules) < n
+}
+
+// match returns the first active rule selecting the request in c.
+func (rs *debugRules) match(c echo.Context) (DebugRule, bool) {
+	rs.mu.Lock()
+	empty := len(rs.rules) == 0
+	rs.mu.Unlock()
+
+	if empty {
+		return DebugRule{}, false
+	}
+
+	for _, r := range rs.list() {
+		if r.matches(c) {
+			return r, true
+		}
+	}
+
+	return DebugRule{}, false
+}
+
+// debugLogging logs the request and response bodies of traffic matching a
+// debug rule, with credentials redacted by redactBody. Matching requests also get a debug-level request-scoped
+// logger, so Context.Logger().Debug output shows for them only.
+func debugLogging(c ServerConfig, rules *debugRules, logHeader string) echo.MiddlewareFunc {
+	maxBody := c.LogConfig.maxBodyBytes()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			rule, ok := rules.match(ctx)
+			if !ok {
+				return next(ctx)
+			}
+
+			ctx.SetLogger(newDebugLogger(ctx.Echo().Logger, logHeader))
+
+			req := ctx.Request()
+
+			var reqBody []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				reqBody, _ = io.ReadAll(io.LimitReader(req.Body, int64(maxBody)))
+				req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(reqBody), req.Body), Closer: req.Body}
+			}
+
+			res := ctx.Response()
+			capture := &limitedCaptureWriter{ResponseWriter: res.Writer, max: maxBody}
+			res.Writer = capture
+
+			err := next(ctx)
+			if err != nil {
+				// Render the error now so its response body is captured too.
+				ctx.Error(err)
+			}
+
+			res.Writer = capture.ResponseWriter
+
+			ctx.Echo().Logger.Printj(log.JSON{
+				"message":       "debug rule matched",
+				"rule":          rule.ID,
+				"method":        req.Method,
+				"uri":           req.RequestURI,
+				"route":         ctx.Path(),
+				"remote_ip":     ctx.RealIP(),
+				"request_id":    req.Header.Get(echo.HeaderXRequestID),
+				"status":        res.Status,
+				"request_body":  redactBody(req.Header.Get(echo.HeaderContentType), reqBody),
+				"response_body": redactBody(res.Header().Get(echo.HeaderContentType), capture.body.Bytes()),
+			})
+
+			return err
+		}
+	}
+}
+
+// credentialKeys are the substrings of field names, lowercased and without
+// "-" and "_", whose values are redacted from logged bodies.
+var credentialKeys = []string{"password", "passwd", "secret", "token", "apikey", "authorization", "cookie", "credential", "privatekey"}
+
+// authSchemeValue matches Authorization-style credentials in any body.
+var authSchemeValue = regexp.MustCompile(`(?i)\b(bearer|basic|digest)\s+[A-Za-z0-9._~+/=-]+`)
+
+// credentialKey reports whether values of the field name are credentials.
+func credentialKey(name string) bool {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+
+	return slices.ContainsFunc(credentialKeys, func(k string) bool { return strings.Contains(name, k) })
+}
+
+// redactBody returns body for logging with credential fields of JSON and
+// form bodies and Authorization-style values replaced. JSON that does not
+// parse, for instance because it was truncated, is omitted.
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	switch {
+	case strings.Contains(contentType, "json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return "[omitted: JSON body did not parse]"
+		}
+
+		b, _ := json.Marshal(redactJSON(v))
+		body = b
+	case strings.HasPrefix(contentType, echo.MIMEApplicationForm):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[omitted: form body did not parse]"
+		}
+
+		for k := range values {
+			if credentialKey(k) {
+				values[k] = []string{redacted}
+			}
+		}
+
+		body = []byte(values.Encode())
+	}
+
+	return authSchemeValue.ReplaceAllString(string(body), "$1 "+redacted)
+}
+
+// redactJSON replaces the values of credential fields in a decoded JSON
+// value.
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if credentialKey(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redactJSON(e)
+		}
+	}
+
+	return v
+}
+
+// readCloser pairs a reader with the closer of the body it replaces.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// limitedCaptureWriter tees up to max bytes of the response body.
+type limitedCaptureWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+	max  int
+}
+
+func (w *limitedCaptureWriter) Write(b []byte) (int, error) {
+	if room := w.max - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(len(b), room)])
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *limitedCaptureWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *limitedCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// newDebugLogger returns a copy of l at debug level, the request-scoped
+// logger of traffic matching a debug rule.
+func newDebugLogger(l echo.Logger, header string) echo.Logger {
+	dl := log.New(l.Prefix())
+	dl.SetOutput(l.Output())
+	dl.SetLevel(log.DEBUG)
+
+	if header != "" {
+		dl.SetHeader(header)
+	}
+
+	return dl
+}
+
+// logLevelHandler serves GET and PUT /admin/log-level. PUT takes
+// {"level": "debug"} and keeps the level until the next reload.
+func (s extServer) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			Level string `json:"level"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := s.SetLogLevel(body.Level); err != nil {
+			writeAdminError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"level": s.LogLevel()})
+}
+
+// debugRulesHandler serves /admin/debug-rules: GET lists the active rules,
+// POST adds one for "duration", such as "15m", and DELETE removes the rule
+// given by the id query parameter, or all of them.
+func (s extServer) debugRulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.DebugRules())
+	case http.MethodPost:
+		var body struct {
+			DebugRule
+			Duration string `json:"duration"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil {
+			writeAdminError(w, http.StatusUnprocessableEntity, fmt.Errorf("debug rule: duration: %w", err))
+			return
+		}
+
+		rule, err := s.AddDebugRule(body.DebugRule, d)
+		if err != nil {
+			writeAdminError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(rule)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if !s.dynamic.rules.remove(id) && id != "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// writeAdminError responds with {"error": err} and code.
+func writeAdminError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// toggleDebug switches the log level to debug, or back to the previous level
+// when it already is debug.
+func (s extServer) toggleDebug() {
+	level := s.dynamic.toggleDebug()
+	s.colorer.Printf("[%s] log level: %s\n", s.colorer.Green("echoext"), s.colorer.Blue(level))
+}
+
+// LogLevel returns the log level in effect.
+func (s extServer) LogLevel() string {
+	return s.dynamic.current.Load().config.LogLevel
+}
+
+// SetLogLevel changes the log level of the server loggers and access log
+// until the next reload.
+func (s extServer) SetLogLevel(level string) error {
+	if err := s.dynamic.setLogLevel(level); err != nil {
+		return err
+	}
+
+	s.colorer.Printf("[%s] log level: %s\n", s.colorer.Green("echoext"), s.colorer.Blue(level))
+
+	return nil
+}
+
+// AddDebugRule logs the bodies of traffic matching rule for d and returns
+// the rule with its ID and expiry.
+func (s extServer) AddDebugRule(rule DebugRule, d time.Duration) (DebugRule, error) {
+	rule, err := s.dynamic.rules.add(rule, d)
+	if err != nil {
+		return rule, err
+	}
+
+	s.colorer.Printf("[%s] debug rule %s: until %s\n", s.colorer.Green("echoext"), s.colorer.Blue(rule.ID), rule.Expires.Format(time.RFC3339))
+
+	return rule, nil
+}
+
+// DebugRules returns the active debug rules.
+func (s extServer) DebugRules() []DebugRule {
+	return s.dynamic.rules.list()
+}
+
+// RemoveDebugRule removes the debug rule with id and reports whether it
+// was active.
+func (s extServer) RemoveDebugRule(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	return s.dynamic.rules.remove(id)
+}
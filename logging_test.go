@@ -0,0 +1,197 @@
+package echoext
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestDebugRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule DebugRule
+		want bool
+	}{
+		{name: "templated route", rule: DebugRule{Route: "/users/:id"}, want: true},
+		{name: "request path", rule: DebugRule{Route: "/users/42"}, want: true},
+		{name: "other route", rule: DebugRule{Route: "/orders/:id"}},
+		{name: "method any case", rule: DebugRule{Method: "post"}, want: true},
+		{name: "other method", rule: DebugRule{Method: http.MethodGet}},
+		{name: "ip", rule: DebugRule{IP: "192.0.2.1"}, want: true},
+		{name: "other ip", rule: DebugRule{IP: "192.0.2.2"}},
+		{name: "request id", rule: DebugRule{RequestID: "req-1"}, want: true},
+		{name: "other request id", rule: DebugRule{RequestID: "req-2"}},
+		{name: "all fields", rule: DebugRule{Route: "/users/:id", Method: http.MethodPost, IP: "192.0.2.1", RequestID: "req-1"}, want: true},
+		{name: "one field differs", rule: DebugRule{Route: "/users/:id", Method: http.MethodPost, IP: "192.0.2.2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/42", nil)
+			req.Header.Set(echo.HeaderXRequestID, "req-1")
+
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			c.SetPath("/users/:id")
+
+			if got := tt.rule.matches(c); got != tt.want {
+				t.Fatalf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugRulesExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires time.Duration
+		want    bool
+	}{
+		{name: "active", expires: time.Minute, want: true},
+		{name: "expired", expires: -time.Second},
+		{name: "expiring now", expires: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &debugRules{rules: []DebugRule{{ID: "r", Route: "/users/:id", Expires: time.Now().Add(tt.expires)}}}
+
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/42", nil), httptest.NewRecorder())
+			c.SetPath("/users/:id")
+
+			if _, got := rs.match(c); got != tt.want {
+				t.Fatalf("match() = %v, want %v", got, tt.want)
+			}
+
+			if got := len(rs.list()) == 1; got != tt.want {
+				t.Fatalf("rule listed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugRulesAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      DebugRule
+		duration  time.Duration
+		wantError bool
+	}{
+		{name: "valid", rule: DebugRule{Method: "get"}, duration: 15 * time.Minute},
+		{name: "at the cap", rule: DebugRule{Method: "get"}, duration: maxDebugRuleDuration},
+		{name: "above the cap", rule: DebugRule{Method: "get"}, duration: maxDebugRuleDuration + time.Second, wantError: true},
+		{name: "zero duration", rule: DebugRule{Method: "get"}, wantError: true},
+		{name: "no field", duration: time.Minute, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rs debugRules
+
+			r, err := rs.add(tt.rule, tt.duration)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("add() = %v, want error %v", err, tt.wantError)
+			}
+
+			if tt.wantError {
+				return
+			}
+
+			if r.ID == "" || r.Method != http.MethodGet || time.Until(r.Expires) > tt.duration {
+				t.Fatalf("add() = %+v", r)
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		hidden      []string
+	}{
+		{
+			name:        "json fields",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"user":"ana","password":"p4ss","nested":{"access_token":"t0k"},"items":[{"API-Key":"k3y"}]}`,
+			want:        `{"items":[{"API-Key":"[REDACTED]"}],"nested":{"access_token":"[REDACTED]"},"password":"[REDACTED]","user":"ana"}`,
+		},
+		{
+			name:        "truncated json",
+			contentType: echo.MIMEApplicationJSONCharsetUTF8,
+			body:        `{"password":"p4`,
+			hidden:      []string{"p4"},
+		},
+		{
+			name:        "form fields",
+			contentType: echo.MIMEApplicationForm,
+			body:        "client_secret=s3cr3t&grant_type=client_credentials&scope=read",
+			hidden:      []string{"s3cr3t"},
+		},
+		{
+			name:        "authorization value in text",
+			contentType: echo.MIMETextPlain,
+			body:        "header was Bearer eyJhbGciOi.abc",
+			want:        "header was Bearer [REDACTED]",
+		},
+		{name: "plain text", contentType: echo.MIMETextPlain, body: "hello", want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody(tt.contentType, []byte(tt.body))
+			if tt.want != "" && got != tt.want {
+				t.Fatalf("redactBody() = %s, want %s", got, tt.want)
+			}
+
+			for _, h := range tt.hidden {
+				if strings.Contains(got, h) {
+					t.Fatalf("redactBody() = %s, leaks %q", got, h)
+				}
+			}
+		})
+	}
+}
+
+func TestLogAdminRequiresProtectedMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     LogConfig
+		metrics MetricsConfig
+		want    int
+	}{
+		{name: "disabled", metrics: MetricsConfig{AllowedCIDRs: []string{"192.0.2.0/24"}}, want: http.StatusNotFound},
+		{name: "unprotected metrics", log: LogConfig{EnableAdmin: true}, want: http.StatusNotFound},
+		{name: "allowlisted", log: LogConfig{EnableAdmin: true}, metrics: MetricsConfig{AllowedCIDRs: []string{"192.0.2.0/24"}}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, LogConfig: tt.log, MetricsConfig: tt.metrics}).(extServer)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			ms, _, err := srv.listenMetrics(ln)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			ms.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug-rules", nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("GET /admin/debug-rules = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package echoext
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -34,6 +37,47 @@ var (
 		Name: "http_requests_in_flight",
 		Help: "Number of HTTP requests currently being served.",
 	})
+
+	// httpRequestsShed counts requests rejected by a concurrency limiter,
+	// partitioned by limiter name and reason (queue_full, queue_timeout,
+	// canceled).
+	httpRequestsShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "Total HTTP requests shed by concurrency limiters, partitioned by limiter and reason.",
+	}, []string{"limiter", "reason"})
+
+	// concurrencyLimitGauge exposes each limiter's current limit, which moves
+	// over time in adaptive modes.
+	concurrencyLimitGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_concurrency_limit",
+		Help: "Current concurrency limit, partitioned by limiter.",
+	}, []string{"limiter"})
+
+	// apiKeyRequests counts API key authenticated requests per key. Keys are
+	// a bounded, operator-managed set, so their IDs are safe as labels.
+	apiKeyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_key_requests_total",
+		Help: "Total requests authenticated by API key, partitioned by key ID and outcome.",
+	}, []string{"key", "outcome"})
+
+	// httpRequestTimeouts counts requests that outlived their deadline.
+	httpRequestTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_request_timeouts_total",
+		Help: "Total HTTP requests that exceeded their deadline, partitioned by method and route.",
+	}, []string{"method", "route"})
+
+	// responseCacheRequests counts response cache lookups by result.
+	responseCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_response_cache_requests_total",
+		Help: "Total response cache lookups, partitioned by cache and result.",
+	}, []string{"cache", "result"})
+
+	// configReloads counts dynamic configuration reloads by trigger and
+	// result.
+	configReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Total dynamic configuration reloads, partitioned by source and result.",
+	}, []string{"source", "result"})
 )
 
 // metricsMiddleware records Prometheus metrics for every request handled by the
@@ -69,13 +113,7 @@ func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			}
 		}
 
-		// Use the templated route to avoid unbounded label cardinality. It is
-		// empty for unmatched paths (404s).
-		route := c.Path()
-		if route == "" {
-			route = "unmatched"
-		}
-
+		route := routeLabel(c.Path())
 		method := req.Method
 		statusStr := strconv.Itoa(status)
 
@@ -86,14 +124,96 @@ func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// routeLabel returns the templated route used as a metric label, avoiding
+// unbounded label cardinality. The path is empty for unmatched paths (404s).
+func routeLabel(path string) string {
+	if path == "" {
+		return "unmatched"
+	}
+
+	return path
+}
+
 // newMetricsServer builds the dedicated HTTP server that exposes the Prometheus
-// metrics endpoint on its own port, isolated from application traffic.
-func newMetricsServer(c ServerConfig) *http.Server {
+// metrics endpoint on its own port, isolated from application traffic. It also
+// serves pprof and the admin endpoints, keyed by path.
+func newMetricsServer(c ServerConfig, admin map[string]http.HandlerFunc) (*http.Server, error) {
+	allowed, err := parseCIDRs(c.MetricsConfig.AllowedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("metrics allowlist: %w", err)
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle(c.MetricsConfig.escapePath(), promhttp.Handler())
+	mux.Handle(c.MetricsConfig.escapePath(), protectMetrics(c.MetricsConfig, allowed, promhttp.Handler()))
+
+	if c.servePprof() {
+		pprofMux := http.NewServeMux()
+		pprofMux.HandleFunc("/debug/pprof/", pprof.Index)
+		pprofMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		pprofMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		pprofMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		pprofMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle("/debug/pprof/", protectMetrics(c.MetricsConfig, allowed, pprofMux))
+	}
+
+	for path, h := range admin {
+		mux.Handle(path, protectMetrics(c.MetricsConfig, allowed, h))
+	}
 
 	return &http.Server{
-		Addr:    fmt.Sprintf(":%d", c.MetricsConfig.escapePort()),
-		Handler: mux,
+		Addr:              c.MetricsConfig.escapeAddr(),
+		Handler:           mux,
+		ReadHeaderTimeout: metricsReadHeaderTimeout,
+	}, nil
+}
+
+// metricsReadHeaderTimeout keeps slow or idle scrapers from holding
+// connections open on the metrics server.
+const metricsReadHeaderTimeout = 5 * time.Second
+
+// protectMetrics wraps the metrics handler with the configured IP allowlist
+// and credential checks. Requests from outside the allowlist are rejected
+// before credentials are inspected.
+func protectMetrics(c MetricsConfig, allowed []*net.IPNet, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allowed) > 0 && !containsIP(allowed, remoteIP(r.RemoteAddr)) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if !metricsAuthorized(c, r) {
+			if c.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			}
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricsAuthorized reports whether the request carries valid credentials.
+// With no credentials configured every request is authorized; otherwise either
+// a matching bearer token or matching basic auth pair is accepted.
+func metricsAuthorized(c MetricsConfig, r *http.Request) bool {
+	if c.Username == "" && c.BearerToken.IsZero() {
+		return true
 	}
+
+	// Values are read per request so rotated secrets apply immediately.
+	if bearer := c.BearerToken.Value(); bearer != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureCompare(token, bearer) {
+			return true
+		}
+	}
+
+	if c.Username != "" {
+		if u, p, ok := r.BasicAuth(); ok && secureCompare(u, c.Username) && secureCompare(p, c.Password.Value()) {
+			return true
+		}
+	}
+
+	return false
 }
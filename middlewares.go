@@ -18,12 +18,13 @@ var defaultCORSHeaders = []string{
 	"Cache-Control", "X-Requested-With",
 }
 
-// CustomCORS creates a CORS middleware with the provided config
+// CustomCORS creates a CORS middleware with the provided config. Origins
+// default to those of the environment profile.
 func CustomCORS(c ServerConfig) echo.MiddlewareFunc {
 	// Combine default headers with any extra headers from config
 	return middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"*"},
-		AllowCredentials: true,
+		AllowOrigins:     c.corsOrigins(),
+		AllowCredentials: !c.CORSConfig.DisableCredentials,
 		AllowMethods: []string{
 			http.MethodGet,
 			http.MethodPost,
@@ -35,8 +36,15 @@ func CustomCORS(c ServerConfig) echo.MiddlewareFunc {
 	})
 }
 
+// CustomLogger logs requests in the environment profile's LogFormat.
 func CustomLogger(c ServerConfig) echo.MiddlewareFunc {
+	var format string
+	if c.profile().LogFormat == TextLogFormat {
+		format = textLogFormat
+	}
+
 	return middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: format,
 		Skipper: func(ctx echo.Context) bool {
 			if ctx.Request().Method == http.MethodOptions {
 				return true
@@ -51,3 +59,30 @@ func CustomLogger(c ServerConfig) echo.MiddlewareFunc {
 		},
 	})
 }
+
+// skipBuiltin wraps m so it is bypassed for CORS preflights and the built-in
+// healthcheck and Swagger endpoints.
+func skipBuiltin(c ServerConfig, m echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		wrapped := m(next)
+		return func(ctx echo.Context) error {
+			if c.isBuiltinRequest(ctx.Request()) {
+				return next(ctx)
+			}
+
+			return wrapped(ctx)
+		}
+	}
+}
+
+// isBuiltinRequest reports whether r is a CORS preflight or targets the
+// healthcheck or Swagger endpoints.
+func (c *ServerConfig) isBuiltinRequest(r *http.Request) bool {
+	if r.Method == http.MethodOptions {
+		return true
+	}
+
+	path := strings.ToLower(r.URL.Path)
+
+	return path == c.healthcheckFullPath() || strings.HasPrefix(path, c.swaggerPath())
+}
@@ -0,0 +1,110 @@
+package echoext
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/color"
+)
+
+// Router registers routes on one of the process's servers.
+type Router interface {
+	Group(string, setupfn, ...MiddlewareFunc) *Group
+	Engine() *echo.Echo
+	Routes() []RouteInfo
+}
+
+// NamedServerConfig declares an additional HTTP server, such as an internal
+// back-office API on its own port. It shares the main server's lifecycle,
+// metrics and default middleware configuration (proxy, security headers,
+// limits, compression, body and timeouts) but has its own address, prefix,
+// CORS, middleware and groups.
+type NamedServerConfig struct {
+	// Name identifies the server in Server.Named and the startup banner.
+	// Required and unique; "main" and "metrics" are reserved.
+	Name       string
+	PathPrefix string
+	Host       string
+	// Port is required unless ListenerConfig selects a listener.
+	Port            int `validate:"gte=0,lte=65535"`
+	HealthcheckPath string
+	// ExtraCORSHeaders extends the default CORS headers.
+	ExtraCORSHeaders []string
+	// DisableCORS drops the CORS middleware, as internal APIs are rarely
+	// called from browsers.
+	DisableCORS bool
+	// Middlewares run on every request except the healthcheck, after the
+	// default stack.
+	Middlewares    []MiddlewareFunc
+	TLSConfig      TLSConfig      `config:"tls"`
+	ListenerConfig ListenerConfig `config:"listener"`
+}
+
+// validateServers reports missing, reserved or duplicate names and servers
+// without an address of their own.
+func validateServers(servers []NamedServerConfig) error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, n := range servers {
+		if n.Name == "" || n.Name == "main" || n.Name == "metrics" || strings.Contains(n.Name, ":") || seen[n.Name] {
+			errs = append(errs, fmt.Errorf("servers: invalid or duplicate server name %s", strconv.Quote(n.Name)))
+		}
+
+		lc := n.ListenerConfig
+		if n.Port == 0 && lc.Listener == nil && lc.UnixSocket == "" && !lc.Systemd {
+			errs = append(errs, fmt.Errorf("server %s: needs a Port or ListenerConfig", strconv.Quote(n.Name)))
+		}
+
+		seen[n.Name] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// serverConfig derives the named server's configuration from the main one.
+func (n NamedServerConfig) serverConfig(main ServerConfig) ServerConfig {
+	c := main
+	c.PathPrefix = n.PathPrefix
+	c.Host = n.Host
+	c.Port = n.Port
+	c.HealthcheckPath = n.HealthcheckPath
+	c.ExtraCORSHeaders = n.ExtraCORSHeaders
+	c.TLSConfig = n.TLSConfig
+	c.ListenerConfig = n.ListenerConfig
+	c.Servers = nil
+
+	c.HealthcheckPath = c.escapeHealthcheckSuffix()
+	c.PathPrefix = c.escapePrefix()
+
+	return c
+}
+
+// newNamedServer builds the server declared by n.
+func newNamedServer(main ServerConfig, n NamedServerConfig, colorer *color.Color, dynamic *dynamicConfig) extServer {
+	c := n.serverConfig(main)
+
+	e, root := newEngine(c, n.Name, !n.DisableCORS, dynamic)
+	for _, m := range n.Middlewares {
+		e.Use(skipBuiltin(c, adaptMiddleware(m)))
+	}
+
+	colorer.Printf("[%s] %s server prefix: %s\n", colorer.Green("echoext"), n.Name, colorer.Blue(c.PathPrefix))
+	colorer.Printf("[%s] %s healthcheck path: %s\n", colorer.Green("echoext"), n.Name, colorer.Blue(c.healthcheckFullPath()))
+
+	routes := &routeTable{}
+
+	return extServer{
+		Echo:    e,
+		config:  c,
+		colorer: colorer,
+		env:     c.Environment,
+		root:    &Group{Group: root, routes: routes},
+		routes:  routes,
+		name:    n.Name,
+		dynamic: dynamic,
+	}
+}
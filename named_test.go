@@ -0,0 +1,29 @@
+package echoext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStartRejectsInvalidServers(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []NamedServerConfig
+		want    string
+	}{
+		{name: "missing name", servers: []NamedServerConfig{{Port: 9000}}, want: `invalid or duplicate server name ""`},
+		{name: "reserved name", servers: []NamedServerConfig{{Name: "metrics", Port: 9000}}, want: `invalid or duplicate server name "metrics"`},
+		{name: "duplicate name", servers: []NamedServerConfig{{Name: "internal", Port: 9000}, {Name: "internal", Port: 9001}}, want: `invalid or duplicate server name "internal"`},
+		{name: "no address", servers: []NamedServerConfig{{Name: "internal"}}, want: `server "internal": needs a Port or ListenerConfig`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}, Servers: tt.servers})
+
+			if err := srv.Start(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Start() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
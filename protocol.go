@@ -0,0 +1,59 @@
+package echoext
+
+import (
+	"net/http"
+	"time"
+)
+
+// ProtocolConfig selects the HTTP versions the main server speaks and tunes
+// connection handling.
+type ProtocolConfig struct {
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge, such as
+	// service mesh sidecars. HTTP/1.1 stays available on the same port.
+	H2C bool `config:"h2c"`
+	// DisableHTTP2 restricts the server to HTTP/1.1, over TLS too.
+	DisableHTTP2 bool
+	// MaxConcurrentStreams bounds the HTTP/2 streams a client may have open
+	// on one connection. Defaults to 250.
+	MaxConcurrentStreams int
+	// MaxHeaderBytes bounds the size of request headers. Defaults to 1 MiB.
+	MaxHeaderBytes int
+	// DisableKeepAlives closes HTTP/1.1 connections after each request.
+	DisableKeepAlives bool
+	// PingInterval sends HTTP/2 pings on connections idle for that long to
+	// detect dead peers. Zero disables pings.
+	PingInterval time.Duration
+}
+
+// apply configures srv's protocols and limits.
+func (c *ProtocolConfig) apply(srv *http.Server) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!c.DisableHTTP2)
+	protocols.SetUnencryptedHTTP2(c.H2C && !c.DisableHTTP2)
+	srv.Protocols = &protocols
+
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: c.MaxConcurrentStreams,
+		SendPingTimeout:      c.PingInterval,
+	}
+
+	if srv.HTTP2.MaxConcurrentStreams <= 0 {
+		srv.HTTP2.MaxConcurrentStreams = 250
+	}
+
+	if c.MaxHeaderBytes > 0 {
+		srv.MaxHeaderBytes = c.MaxHeaderBytes
+	}
+
+	srv.SetKeepAlivesEnabled(!c.DisableKeepAlives)
+}
+
+// nextProtos returns the ALPN protocols offered over TLS.
+func (c *ProtocolConfig) nextProtos() []string {
+	if c.DisableHTTP2 {
+		return []string{"http/1.1"}
+	}
+
+	return []string{"h2", "http/1.1"}
+}
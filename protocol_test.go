@@ -0,0 +1,63 @@
+package echoext
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestH2C(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol ProtocolConfig
+		// h2c makes the client speak HTTP/2 with prior knowledge instead of
+		// HTTP/1.1.
+		h2c       bool
+		wantProto int
+		wantError bool
+	}{
+		{name: "h2c", protocol: ProtocolConfig{H2C: true}, h2c: true, wantProto: 2},
+		{name: "http/1.1 next to h2c", protocol: ProtocolConfig{H2C: true}, wantProto: 1},
+		{name: "h2c disabled", h2c: true, wantError: true},
+		{name: "http2 disabled", protocol: ProtocolConfig{H2C: true, DisableHTTP2: true}, h2c: true, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, ProtocolConfig: tt.protocol, MetricsConfig: MetricsConfig{Disabled: true}}).(extServer)
+			srv.Group("proto", func(g *Group) {
+				g.GET("", func(c Context) error { return c.String(http.StatusOK, c.Request().Proto) })
+			})
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv.Echo.Server.Handler = srv.Echo
+			go func() { _ = srv.Echo.Server.Serve(ln) }()
+			defer srv.Echo.Server.Close()
+
+			var protocols http.Protocols
+			protocols.SetHTTP1(!tt.h2c)
+			protocols.SetUnencryptedHTTP2(tt.h2c)
+
+			transport := &http.Transport{Protocols: &protocols}
+			defer transport.CloseIdleConnections()
+
+			res, err := (&http.Client{Transport: transport}).Get("http://" + ln.Addr().String() + "/proto")
+			if (err != nil) != tt.wantError {
+				t.Fatalf("GET = %v, want error %v", err, tt.wantError)
+			}
+
+			if tt.wantError {
+				return
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK || res.ProtoMajor != tt.wantProto {
+				t.Fatalf("response = %d over HTTP/%d, want 200 over HTTP/%d", res.StatusCode, res.ProtoMajor, tt.wantProto)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package echoext
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// proxyKey holds the server's *proxyResolver so Context.Scheme can use it.
+const proxyKey = "echoext.proxy"
+
+// ProxyHeader selects the header trusted proxies report the client in.
+type ProxyHeader string
+
+const (
+	// ProxyXForwardedFor reads the X-Forwarded-For chain and X-Forwarded-Proto.
+	ProxyXForwardedFor ProxyHeader = "x-forwarded-for"
+	// ProxyXRealIP reads X-Real-Ip and X-Forwarded-Proto.
+	ProxyXRealIP ProxyHeader = "x-real-ip"
+	// ProxyForwarded reads the RFC 7239 Forwarded header's for and proto
+	// parameters.
+	ProxyForwarded ProxyHeader = "forwarded"
+)
+
+// ProxyConfig declares the reverse proxies whose forwarding headers are
+// trusted. With no TrustedProxies, forwarding headers are ignored and the
+// client is the TCP peer.
+type ProxyConfig struct {
+	// TrustedProxies are the CIDRs or IPs of trusted proxies.
+	TrustedProxies []string
+	// Header defaults to ProxyXForwardedFor.
+	Header ProxyHeader
+}
+
+// proxyResolver derives the client IP and scheme of requests.
+type proxyResolver struct {
+	trusted []*net.IPNet
+	header  ProxyHeader
+}
+
+func (c ProxyConfig) resolver() (*proxyResolver, error) {
+	trusted, err := parseCIDRs(c.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("proxy config: %w", err)
+	}
+
+	header := c.Header
+	switch header {
+	case "":
+		header = ProxyXForwardedFor
+	case ProxyXForwardedFor, ProxyXRealIP, ProxyForwarded:
+	default:
+		return nil, fmt.Errorf("proxy config: unknown header %q", c.Header)
+	}
+
+	return &proxyResolver{trusted: trusted, header: header}, nil
+}
+
+// validate reports an invalid CIDR or header.
+func (c ProxyConfig) validate() error {
+	_, err := c.resolver()
+	return err
+}
+
+// schemeHeaders are the request headers echo.Context.Scheme reads.
+var schemeHeaders = []string{echo.HeaderXForwardedProto, echo.HeaderXForwardedProtocol, echo.HeaderXForwardedSsl, echo.HeaderXUrlScheme}
+
+// apply installs the resolver on e, so RealIP, Scheme, the access logger and
+// rate limiting all see the same client. Echo's own Scheme, used by echo
+// middleware, is kept in line by rewriting the scheme headers to the
+// resolved scheme.
+func (p *proxyResolver) apply(e *echo.Echo) {
+	e.IPExtractor = p.clientIP
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(proxyKey, p)
+			p.rewriteScheme(c.Request())
+			return next(c)
+		}
+	})
+}
+
+// rewriteScheme replaces the scheme headers with X-Forwarded-Proto: https
+// when a trusted proxy reported HTTPS, and drops them otherwise.
+func (p *proxyResolver) rewriteScheme(r *http.Request) {
+	scheme := p.scheme(r)
+
+	for _, h := range schemeHeaders {
+		r.Header.Del(h)
+	}
+
+	if scheme == "https" && r.TLS == nil {
+		r.Header.Set(echo.HeaderXForwardedProto, scheme)
+	}
+}
+
+func (p *proxyResolver) isTrusted(ip net.IP) bool {
+	return ip != nil && containsIP(p.trusted, ip)
+}
+
+// clientIP returns the TCP peer unless it is a trusted proxy. Behind trusted
+// proxies, the forwarding chain is walked from the nearest hop outwards and
+// the first untrusted address is the client.
+func (p *proxyResolver) clientIP(r *http.Request) string {
+	peer := remoteIP(r.RemoteAddr)
+	if !p.isTrusted(peer) {
+		if peer == nil {
+			return r.RemoteAddr
+		}
+
+		return peer.String()
+	}
+
+	if p.header == ProxyXRealIP {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get(echo.HeaderXRealIP))); ip != nil {
+			return ip.String()
+		}
+
+		return peer.String()
+	}
+
+	client, _ := p.walk(p.hops(r))
+	if client == nil {
+		return peer.String()
+	}
+
+	return client.String()
+}
+
+// scheme returns "https" for TLS connections and otherwise the scheme
+// reported by a trusted proxy, defaulting to "http".
+func (p *proxyResolver) scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if !p.isTrusted(remoteIP(r.RemoteAddr)) {
+		return "http"
+	}
+
+	var proto string
+	if p.header == ProxyForwarded {
+		_, hop := p.walk(p.hops(r))
+		proto = hop.proto
+	} else {
+		proto, _, _ = strings.Cut(r.Header.Get(echo.HeaderXForwardedProto), ",")
+	}
+
+	if strings.EqualFold(strings.TrimSpace(proto), "https") {
+		return "https"
+	}
+
+	return "http"
+}
+
+// proxyHop is one entry of a forwarding chain.
+type proxyHop struct {
+	ip    net.IP
+	proto string
+}
+
+// hops returns the forwarding chain, client first.
+func (p *proxyResolver) hops(r *http.Request) []proxyHop {
+	var hops []proxyHop
+
+	if p.header == ProxyForwarded {
+		for _, line := range r.Header.Values("Forwarded") {
+			for _, element := range strings.Split(line, ",") {
+				var hop proxyHop
+				for _, pair := range strings.Split(element, ";") {
+					k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+					v = strings.Trim(v, `"`)
+					switch strings.ToLower(k) {
+					case "for":
+						hop.ip = parseHopIP(v)
+					case "proto":
+						hop.proto = v
+					}
+				}
+
+				hops = append(hops, hop)
+			}
+		}
+
+		return hops
+	}
+
+	for _, line := range r.Header.Values(echo.HeaderXForwardedFor) {
+		for _, v := range strings.Split(line, ",") {
+			hops = append(hops, proxyHop{ip: parseHopIP(strings.TrimSpace(v))})
+		}
+	}
+
+	return hops
+}
+
+// walk returns the client of the chain: the nearest untrusted hop, or the
+// outermost hop when every hop is trusted. It stops at malformed or
+// obfuscated entries, which cannot be attributed.
+func (p *proxyResolver) walk(hops []proxyHop) (net.IP, proxyHop) {
+	var client net.IP
+	var last proxyHop
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hops[i].ip == nil {
+			break
+		}
+
+		client, last = hops[i].ip, hops[i]
+		if !p.isTrusted(client) {
+			break
+		}
+	}
+
+	return client, last
+}
+
+// parseHopIP parses an address from a forwarding header, with or without a
+// port and IPv6 brackets.
+func parseHopIP(v string) net.IP {
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+
+	return net.ParseIP(strings.Trim(v, "[]"))
+}
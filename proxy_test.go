@@ -0,0 +1,89 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestProxyScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		wantScheme string
+		wantHSTS   bool
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:1234", wantScheme: "http"},
+		{name: "untrusted proto", remoteAddr: "203.0.113.7:1234", header: map[string]string{"X-Forwarded-Proto": "https"}, wantScheme: "http"},
+		{name: "untrusted ssl", remoteAddr: "203.0.113.7:1234", header: map[string]string{"X-Forwarded-Ssl": "on"}, wantScheme: "http"},
+		{name: "untrusted url scheme", remoteAddr: "203.0.113.7:1234", header: map[string]string{"X-Url-Scheme": "https"}, wantScheme: "http"},
+		{name: "trusted proto", remoteAddr: "10.0.0.2:1234", header: map[string]string{"X-Forwarded-Proto": "https"}, wantScheme: "https", wantHSTS: true},
+		{name: "trusted ssl ignored", remoteAddr: "10.0.0.2:1234", header: map[string]string{"X-Forwarded-Ssl": "on"}, wantScheme: "http"},
+		{name: "trusted http", remoteAddr: "10.0.0.2:1234", header: map[string]string{"X-Forwarded-Proto": "http"}, wantScheme: "http"},
+	}
+
+	srv := New(ServerConfig{
+		Environment:   Production,
+		ProxyConfig:   ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+		MetricsConfig: MetricsConfig{Disabled: true},
+	})
+
+	// A plain echo route sees echo's own Scheme, as echo middleware does.
+	srv.Engine().GET("/scheme", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Scheme())
+	})
+
+	srv.Group("ours", func(g *Group) {
+		g.GET("", func(c Context) error {
+			return c.String(http.StatusOK, c.Scheme())
+		})
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/scheme", "/ours"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = tt.remoteAddr
+				for k, v := range tt.header {
+					req.Header.Set(k, v)
+				}
+
+				rec := httptest.NewRecorder()
+				srv.Engine().ServeHTTP(rec, req)
+
+				if got := rec.Body.String(); got != tt.wantScheme {
+					t.Errorf("%s: scheme = %q, want %q", path, got, tt.wantScheme)
+				}
+
+				if path == "/ours" && (rec.Header().Get("Strict-Transport-Security") != "") != tt.wantHSTS {
+					t.Errorf("HSTS = %q, want present %v", rec.Header().Get("Strict-Transport-Security"), tt.wantHSTS)
+				}
+			}
+		})
+	}
+}
+
+func TestStartRejectsInvalidProxyConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy ProxyConfig
+		want  string
+	}{
+		{name: "invalid cidr", proxy: ProxyConfig{TrustedProxies: []string{"10.0.0.0/33"}}, want: "proxy config"},
+		{name: "unknown header", proxy: ProxyConfig{Header: "X-Client-IP"}, want: `unknown header "X-Client-IP"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{Environment: Production, ProxyConfig: tt.proxy, MetricsConfig: MetricsConfig{Disabled: true}})
+
+			if err := srv.Start(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Start() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
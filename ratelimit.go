@@ -0,0 +1,357 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm selects how requests are counted against a limit.
+type RateLimitAlgorithm string
+
+const (
+	// TokenBucket refills Limit tokens per Window up to Burst, allowing short
+	// bursts while enforcing the average rate.
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	// SlidingWindow allows at most Limit requests in any rolling Window,
+	// approximated from the current and previous fixed windows.
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// SubjectKey is the context key holding the authenticated subject. Auth
+// middlewares set it and KeyBySubject reads it.
+const SubjectKey = "subject"
+
+// RateLimitKeyFunc derives the bucket key for a request. Returning an empty
+// string falls back to the client IP.
+type RateLimitKeyFunc func(c Context) string
+
+// RateLimitConfig configures the rate limiting middleware. The global limiter
+// on ServerConfig is enabled when Limit is greater than zero.
+type RateLimitConfig struct {
+	// Algorithm defaults to TokenBucket.
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	Limit int
+	// Window defaults to one minute. Windows under a millisecond are
+	// rejected.
+	Window time.Duration `validate:"omitempty,gte=1ms"`
+	// Burst is the token bucket capacity. Defaults to Limit. Ignored by
+	// SlidingWindow.
+	Burst int
+	// KeyFunc defaults to KeyByIP.
+	KeyFunc RateLimitKeyFunc
+	// Store defaults to a new in-memory store per middleware instance.
+	Store RateLimitStore
+	// Prefix namespaces keys so limiters sharing a Store do not collide.
+	// Unnamed limiters get "default", "default_2" and so on in creation
+	// order, so set it when several limiters share a Store.
+	Prefix string
+}
+
+// RateLimitRule is the normalized rule handed to a RateLimitStore.
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	Burst     int
+}
+
+// RateLimitResult is the outcome of consuming one request from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps rate limit state. Implementations must be safe for
+// concurrent use.
+type RateLimitStore interface {
+	// Take consumes one request for key under rule and reports the decision.
+	Take(ctx stdcontext.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// KeyByIP keys requests by client IP.
+func KeyByIP(c Context) string {
+	return c.RealIP()
+}
+
+// KeyByHeader keys requests by the value of header, such as an API key.
+func KeyByHeader(header string) RateLimitKeyFunc {
+	return func(c Context) string {
+		if v := c.Request().Header.Get(header); v != "" {
+			return "h:" + v
+		}
+
+		return ""
+	}
+}
+
+// KeyBySubject keys requests by the authenticated subject stored under
+// SubjectKey.
+func KeyBySubject(c Context) string {
+	if v := c.GetString(SubjectKey); v != "" {
+		return "s:" + v
+	}
+
+	return ""
+}
+
+func (c *RateLimitConfig) enabled() bool {
+	return c.Limit > 0
+}
+
+func (c *RateLimitConfig) rule() RateLimitRule {
+	r := RateLimitRule{
+		Algorithm: c.Algorithm,
+		Limit:     c.Limit,
+		Window:    c.Window,
+		Burst:     c.Burst,
+	}
+
+	if r.Algorithm == "" {
+		r.Algorithm = TokenBucket
+	}
+
+	if r.Window <= 0 {
+		r.Window = time.Minute
+	}
+
+	if r.Burst <= 0 {
+		r.Burst = r.Limit
+	}
+
+	return r
+}
+
+// validate rejects windows under a millisecond, the resolution of the
+// stores.
+func (c *RateLimitConfig) validate() error {
+	if c.Window > 0 && c.Window < time.Millisecond {
+		return fmt.Errorf("rate limit: Window must be at least 1ms, got %s", c.Window)
+	}
+
+	return nil
+}
+
+// RateLimit returns a middleware that throttles requests according to cfg. It
+// sets RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset on every
+// response and Retry-After when rejecting with 429. Store errors are logged
+// and the request is let through. It panics on a Window under a millisecond.
+func RateLimit(cfg RateLimitConfig) MiddlewareFunc {
+	if err := cfg.validate(); err != nil {
+		panic("echoext: " + err.Error())
+	}
+
+	rule := cfg.rule()
+
+	return rateLimit(cfg, func() RateLimitRule { return rule })
+}
+
+// rateLimit is RateLimit with the rule read on every request, so it can
+// change at runtime.
+func rateLimit(cfg RateLimitConfig, currentRule func() RateLimitRule) MiddlewareFunc {
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = defaultLimiterName("rate")
+	}
+
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			rule := currentRule()
+			if rule.Limit <= 0 {
+				return next(c)
+			}
+
+			key := keyFunc(c)
+			if key == "" {
+				key = "ip:" + c.RealIP()
+			}
+
+			res, err := store.Take(c.Request().Context(), prefix+":"+key, rule)
+			if err != nil {
+				c.Logger().Errorf("rate limit store: %v", err)
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				return newHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, as required by Retry-After and
+// RateLimit-Reset.
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(d.Seconds()))
+}
+
+// tokenBucketResult derives the result from the bucket level left after a
+// take attempt.
+func tokenBucketResult(rule RateLimitRule, tokens float64, allowed bool) RateLimitResult {
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rule.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(rule.Burst) - tokens) / rate * float64(time.Second)),
+	}
+
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+
+	return res
+}
+
+// slidingWindowResult derives the result from the estimated request count in
+// the rolling window. untilNext is the time left in the current fixed window.
+func slidingWindowResult(rule RateLimitRule, estimate float64, allowed bool, untilNext time.Duration) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: max(0, rule.Limit-int(math.Ceil(estimate))),
+		Reset:     untilNext,
+	}
+
+	if !allowed {
+		res.RetryAfter = untilNext
+	}
+
+	return res
+}
+
+// slidingWindowPosition returns the index of the fixed window containing now,
+// the weight of the previous window in the rolling estimate and the time left
+// until the next window starts.
+func slidingWindowPosition(now time.Time, window time.Duration) (int64, float64, time.Duration) {
+	elapsed := time.Duration(now.UnixNano() % int64(window))
+
+	return now.UnixNano() / int64(window), 1 - float64(elapsed)/float64(window), window - elapsed
+}
+
+// memoryRateLimitSweepInterval bounds how often idle buckets are evicted.
+const memoryRateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is an in-process RateLimitStore. State is not shared
+// between replicas.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	last    time.Time
+	window  int64
+	curr    int
+	prev    int
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]*memoryBucket{},
+		now:     time.Now,
+	}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ stdcontext.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(rule.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	// Idle buckets are kept for two windows so the sliding estimate still
+	// sees the previous window.
+	b.expires = now.Add(2 * rule.Window)
+
+	if rule.Algorithm == SlidingWindow {
+		idx, weight, untilNext := slidingWindowPosition(now, rule.Window)
+		switch idx - b.window {
+		case 0:
+		case 1:
+			b.prev, b.curr = b.curr, 0
+		default:
+			b.prev, b.curr = 0, 0
+		}
+		b.window = idx
+
+		estimate := float64(b.prev)*weight + float64(b.curr)
+		if estimate+1 > float64(rule.Limit) {
+			return slidingWindowResult(rule, estimate, false, untilNext), nil
+		}
+
+		b.curr++
+
+		return slidingWindowResult(rule, estimate+1, true, untilNext), nil
+	}
+
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return tokenBucketResult(rule, b.tokens, false), nil
+	}
+
+	b.tokens--
+
+	return tokenBucketResult(rule, b.tokens, true), nil
+}
+
+// sweep evicts expired buckets at most once per sweep interval. Callers must
+// hold s.mu.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryRateLimitSweepInterval {
+		return
+	}
+
+	s.lastSweep = now
+	for k, b := range s.buckets {
+		if now.After(b.expires) {
+			delete(s.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes from a bucket stored as a hash. The
+// level is returned as a string because Redis truncates Lua numbers.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 't', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 't', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript counts requests in the current fixed window (KEYS[1])
+// and weighs in the previous one (KEYS[2]).
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local estimate = prev * weight + curr
+
+if estimate + 1 > limit then
+	return {0, tostring(estimate)}
+end
+
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return {1, tostring(estimate + 1)}
+`)
+
+// RedisRateLimitStore is a RateLimitStore backed by Redis, sharing limits
+// across replicas. Keys use a hash tag so both sliding window keys land on
+// the same cluster slot.
+type RedisRateLimitStore struct {
+	client redis.Scripter
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisRateLimitStore creates a store using client. Keys are written under
+// "echoext:ratelimit:".
+func NewRedisRateLimitStore(client redis.Scripter) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client: client,
+		prefix: "echoext:ratelimit:",
+		now:    time.Now,
+	}
+}
+
+// Take implements RateLimitStore.
+func (s *RedisRateLimitStore) Take(ctx stdcontext.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	// Rates are computed per millisecond.
+	if rule.Window < time.Millisecond {
+		return RateLimitResult{}, fmt.Errorf("redis rate limit: window %s is under 1ms", rule.Window)
+	}
+
+	now := s.now()
+	ttl := (2 * rule.Window).Milliseconds()
+
+	if rule.Algorithm == SlidingWindow {
+		idx, weight, untilNext := slidingWindowPosition(now, rule.Window)
+		keys := []string{
+			fmt.Sprintf("%s{%s}:%d", s.prefix, key, idx),
+			fmt.Sprintf("%s{%s}:%d", s.prefix, key, idx-1),
+		}
+
+		allowed, estimate, err := runLimitScript(ctx, s.client, slidingWindowScript, keys, rule.Limit, weight, ttl)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+
+		return slidingWindowResult(rule, estimate, allowed, untilNext), nil
+	}
+
+	ratePerMs := float64(rule.Limit) / float64(rule.Window.Milliseconds())
+	keys := []string{s.prefix + "{" + key + "}"}
+
+	allowed, tokens, err := runLimitScript(ctx, s.client, tokenBucketScript, keys, ratePerMs, rule.Burst, now.UnixMilli(), ttl)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return tokenBucketResult(rule, tokens, allowed), nil
+}
+
+// runLimitScript runs one of the limiter scripts and decodes its
+// {allowed, value} reply.
+func runLimitScript(ctx stdcontext.Context, client redis.Scripter, script *redis.Script, keys []string, args ...any) (bool, float64, error) {
+	reply, err := script.Run(ctx, client, keys, args...).Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("redis rate limit: %w", err)
+	}
+
+	if len(reply) != 2 {
+		return false, 0, fmt.Errorf("redis rate limit: unexpected reply %v", reply)
+	}
+
+	allowed, _ := reply[0].(int64)
+	raw, _ := reply[1].(string)
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("redis rate limit: %w", err)
+	}
+
+	return allowed == 1, value, nil
+}
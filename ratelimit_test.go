@@ -0,0 +1,124 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimitStores(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	stores := map[string]func(now func() time.Time) RateLimitStore{
+		"memory": func(now func() time.Time) RateLimitStore {
+			s := NewMemoryRateLimitStore()
+			s.now = now
+			return s
+		},
+		"redis": func(now func() time.Time) RateLimitStore {
+			s := NewRedisRateLimitStore(client)
+			s.now = now
+			return s
+		},
+	}
+
+	tests := []struct {
+		name string
+		rule RateLimitRule
+		// takes are the offsets from the start at which requests arrive.
+		takes   []time.Duration
+		allowed []bool
+	}{
+		{
+			name:    "token bucket exhausts burst",
+			rule:    RateLimitRule{Algorithm: TokenBucket, Limit: 2, Window: time.Minute, Burst: 2},
+			takes:   []time.Duration{0, 0, 0},
+			allowed: []bool{true, true, false},
+		},
+		{
+			name:    "token bucket refills",
+			rule:    RateLimitRule{Algorithm: TokenBucket, Limit: 1, Window: time.Second, Burst: 1},
+			takes:   []time.Duration{0, 0, time.Second},
+			allowed: []bool{true, false, true},
+		},
+		{
+			name:    "sliding window limits",
+			rule:    RateLimitRule{Algorithm: SlidingWindow, Limit: 2, Window: time.Minute},
+			takes:   []time.Duration{0, time.Second, 2 * time.Second},
+			allowed: []bool{true, true, false},
+		},
+		{
+			name:    "sliding window weighs previous window",
+			rule:    RateLimitRule{Algorithm: SlidingWindow, Limit: 2, Window: time.Minute},
+			takes:   []time.Duration{0, 0, time.Minute, 2 * time.Minute},
+			allowed: []bool{true, true, false, true},
+		},
+		{
+			name:    "millisecond window",
+			rule:    RateLimitRule{Algorithm: TokenBucket, Limit: 1, Window: time.Millisecond, Burst: 1},
+			takes:   []time.Duration{0, 0, time.Millisecond},
+			allowed: []bool{true, false, true},
+		},
+	}
+
+	// Aligned to a window boundary so sliding window offsets are exact.
+	start := time.Now().Truncate(time.Hour).Add(time.Hour)
+
+	for storeName, newStore := range stores {
+		for i, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				now := start
+				store := newStore(func() time.Time { return now })
+				key := tt.name + storeName + string(rune('a'+i))
+
+				for j, offset := range tt.takes {
+					now = start.Add(offset)
+
+					res, err := store.Take(stdcontext.Background(), key, tt.rule)
+					if err != nil {
+						t.Fatalf("take %d: %v", j, err)
+					}
+
+					if res.Allowed != tt.allowed[j] {
+						t.Fatalf("take %d at %s: allowed = %v, want %v", j, offset, res.Allowed, tt.allowed[j])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRedisRateLimitStoreRejectsSubMillisecondWindow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	rule := RateLimitRule{Algorithm: TokenBucket, Limit: 1, Window: time.Microsecond, Burst: 1}
+	if _, err := NewRedisRateLimitStore(client).Take(stdcontext.Background(), "k", rule); err == nil {
+		t.Fatal("Take accepted a window under 1ms")
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	tests := []struct {
+		window  time.Duration
+		wantErr bool
+	}{
+		{window: 0},
+		{window: time.Millisecond},
+		{window: time.Minute},
+		{window: time.Microsecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c := RateLimitConfig{Limit: 1, Window: tt.window}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Window %s: validate() = %v, want error %v", tt.window, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package echoext
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// dynamicKey holds the server's *dynamicConfig so Context.Feature can read
+// the current toggles.
+const dynamicKey = "echoext.dynamic"
+
+// DynamicConfig is the configuration that can change without a restart.
+// Empty fields keep the values from ServerConfig, so a reload can replace a
+// static setting but not turn it off: removing CORSOrigins or RateLimit from
+// the file restores the ServerConfig values, not "no CORS" or "no limit".
+type DynamicConfig struct {
+	// CORSOrigins replaces the allowed CORS origins.
+	CORSOrigins []string
+	// RateLimit replaces the limit, window and burst of the global
+	// RateLimitConfig. A limit set here enables global rate limiting.
+	RateLimit DynamicRateLimit
+	// LogLevel is debug, info, warn, error or off.
+	LogLevel string `validate:"omitempty,oneof=debug info warn error off"`
+	// Features are the enabled feature toggles, read with Context.Feature.
+	Features []string
+}
+
+// DynamicRateLimit is the reloadable part of RateLimitConfig.
+type DynamicRateLimit struct {
+	Limit  int           `validate:"gte=0"`
+	Window time.Duration `validate:"omitempty,gte=1ms"`
+	Burst  int           `validate:"gte=0"`
+}
+
+// ReloadConfig enables reloading DynamicConfig from File while the server
+// runs. A reload is triggered when the file changes, on Signal and by
+// POST /admin/reload on the metrics server when it has access control. The
+// new configuration is
+// validated and swapped atomically; an invalid one is logged and the
+// previous configuration stays in effect.
+type ReloadConfig struct {
+	// File holds the DynamicConfig as YAML, JSON or TOML. Required to enable
+	// reloading.
+	File string
+	// EnvPrefix is passed to LoadConfig, so environment variables override
+	// File as they do at startup. Without it, environment variables are not
+	// read.
+	EnvPrefix string
+	// PollInterval is how often File is checked for changes. Defaults to 10
+	// seconds; negative disables polling.
+	PollInterval time.Duration
+	// Signal triggers a reload. Defaults to SIGUSR2, leaving SIGHUP to
+	// RestartConfig. It must differ from RestartConfig's signal, or Start
+	// returns an error.
+	Signal os.Signal
+}
+
+func (c *ReloadConfig) enabled() bool {
+	return c.File != ""
+}
+
+func (c *ReloadConfig) pollInterval() time.Duration {
+	if c.PollInterval == 0 {
+		return 10 * time.Second
+	}
+
+	return c.PollInterval
+}
+
+func (c *ReloadConfig) signal() os.Signal {
+	if c.Signal == nil {
+		return syscall.SIGUSR2
+	}
+
+	return c.Signal
+}
+
+// dynamicState is an effective DynamicConfig with its derived values.
+type dynamicState struct {
+	config   DynamicConfig
+	rule     RateLimitRule
+	level    log.Lvl
+	features map[string]bool
+}
+
+// dynamicConfig holds the current dynamic configuration of a server and its
+// named servers.
+type dynamicConfig struct {
+	static  ServerConfig
+	current atomic.Pointer[dynamicState]
+	rules   debugRules
+
+	// mu serializes reloads and guards the fields below.
+	mu      sync.Mutex
+	onApply []func(*dynamicState)
+	modTime time.Time
+	size    int64
+	// beforeDebug is the level toggleDebug switches back to.
+	beforeDebug string
+}
+
+// newDynamicConfig returns the dynamic configuration of c, loading
+// ReloadConfig.File when set. When the file cannot be loaded, the returned
+// configuration holds the static values and the error is returned with it.
+func newDynamicConfig(c ServerConfig) (*dynamicConfig, error) {
+	d := &dynamicConfig{static: c}
+
+	var dc DynamicConfig
+	var err error
+	if c.ReloadConfig.enabled() {
+		d.modTime, d.size = d.stat()
+
+		if dc, err = d.load(); err != nil {
+			dc, err = DynamicConfig{}, fmt.Errorf("config reload: %w", err)
+		}
+	}
+
+	d.current.Store(d.state(dc))
+
+	return d, err
+}
+
+// load reads the dynamic configuration file.
+func (d *dynamicConfig) load() (DynamicConfig, error) {
+	var dc DynamicConfig
+	err := LoadConfig(&dc, LoaderConfig{
+		Files:     []string{d.static.ReloadConfig.File},
+		EnvPrefix: d.static.ReloadConfig.EnvPrefix,
+	})
+
+	return dc, err
+}
+
+// state fills the empty fields of dc from the static configuration.
+func (d *dynamicConfig) state(dc DynamicConfig) *dynamicState {
+	c := d.static
+
+	if len(dc.CORSOrigins) == 0 {
+		dc.CORSOrigins = c.corsOrigins()
+	}
+
+	rl := c.RateLimitConfig
+	if dc.RateLimit.Limit > 0 {
+		rl.Limit = dc.RateLimit.Limit
+	}
+
+	if dc.RateLimit.Window > 0 {
+		rl.Window = dc.RateLimit.Window
+	}
+
+	if dc.RateLimit.Burst > 0 {
+		rl.Burst = dc.RateLimit.Burst
+	}
+
+	rule := rl.rule()
+	dc.RateLimit = DynamicRateLimit{}
+	if rule.Limit > 0 {
+		dc.RateLimit = DynamicRateLimit{Limit: rule.Limit, Window: rule.Window, Burst: rule.Burst}
+	}
+
+	if dc.LogLevel == "" {
+		dc.LogLevel = "error"
+		if c.profile().Debug {
+			dc.LogLevel = "debug"
+		}
+	}
+
+	features := make(map[string]bool, len(dc.Features))
+	for _, f := range dc.Features {
+		features[f] = true
+	}
+
+	return &dynamicState{
+		config:   dc,
+		rule:     rule,
+		level:    logLevels[dc.LogLevel],
+		features: features,
+	}
+}
+
+var logLevels = map[string]log.Lvl{
+	"debug": log.DEBUG,
+	"info":  log.INFO,
+	"warn":  log.WARN,
+	"error": log.ERROR,
+	"off":   log.OFF,
+}
+
+// apply registers fn to run with the current state now and after every
+// reload.
+func (d *dynamicConfig) apply(fn func(*dynamicState)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onApply = append(d.onApply, fn)
+	fn(d.current.Load())
+}
+
+// reload loads the file and swaps it in when valid. source labels the
+// trigger in logs and metrics.
+func (d *dynamicConfig) reload(source string) (DynamicConfig, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.modTime, d.size = d.stat()
+
+	dc, err := d.load()
+	if err != nil {
+		configReloads.WithLabelValues(source, "failure").Inc()
+		return d.current.Load().config, err
+	}
+
+	st := d.state(dc)
+	d.current.Store(st)
+
+	for _, fn := range d.onApply {
+		fn(st)
+	}
+
+	configReloads.WithLabelValues(source, "success").Inc()
+
+	return st.config, nil
+}
+
+// setLogLevel swaps in the current state with level.
+func (d *dynamicConfig) setLogLevel(level string) error {
+	lvl, ok := logLevels[level]
+	if !ok {
+		return fmt.Errorf("log level %q: must be debug, info, warn, error or off", level)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.swapLevel(level, lvl)
+
+	return nil
+}
+
+// toggleDebug switches to debug, or back to the level before it, and
+// returns the new level.
+func (d *dynamicConfig) toggleDebug() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	level := "debug"
+	if cur := d.current.Load().config.LogLevel; cur == "debug" {
+		level = d.beforeDebug
+		if level == "" || level == "debug" {
+			level = "error"
+		}
+	} else {
+		d.beforeDebug = cur
+	}
+
+	d.swapLevel(level, logLevels[level])
+
+	return level
+}
+
+// swapLevel stores a copy of the current state with the level changed.
+// d.mu must be held.
+func (d *dynamicConfig) swapLevel(level string, lvl log.Lvl) {
+	st := *d.current.Load()
+	st.config.LogLevel = level
+	st.level = lvl
+	d.current.Store(&st)
+
+	for _, fn := range d.onApply {
+		fn(&st)
+	}
+}
+
+// stat returns the file's modification time and size.
+func (d *dynamicConfig) stat() (time.Time, int64) {
+	fi, err := os.Stat(d.static.ReloadConfig.File)
+	if err != nil {
+		return time.Time{}, -1
+	}
+
+	return fi.ModTime(), fi.Size()
+}
+
+// changed reports whether the file changed since it was last read.
+func (d *dynamicConfig) changed() bool {
+	modTime, size := d.stat()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return !modTime.Equal(d.modTime) || size != d.size
+}
+
+// rateLimitRule returns the current global rate limit rule.
+func (d *dynamicConfig) rateLimitRule() RateLimitRule {
+	return d.current.Load().rule
+}
+
+// middleware exposes d to handlers through Context.Feature.
+func (d *dynamicConfig) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Set(dynamicKey, d)
+		return next(c)
+	}
+}
+
+// dynamicCORS is CustomCORS with the origins read from d. The CORS
+// middleware is rebuilt after each reload; without origins, requests pass
+// through without CORS headers.
+func dynamicCORS(c ServerConfig, d *dynamicConfig) echo.MiddlewareFunc {
+	type built struct {
+		state *dynamicState
+		h     echo.HandlerFunc
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		var cur atomic.Pointer[built]
+
+		return func(ctx echo.Context) error {
+			st := d.current.Load()
+			if len(st.config.CORSOrigins) == 0 {
+				return next(ctx)
+			}
+
+			b := cur.Load()
+			if b == nil || b.state != st {
+				cc := c
+				cc.CORSConfig.AllowOrigins = st.config.CORSOrigins
+				b = &built{state: st, h: CustomCORS(cc)(next)}
+				cur.Store(b)
+			}
+
+			return b.h(ctx)
+		}
+	}
+}
+
+// reloadHandler serves POST /admin/reload, responding with the effective
+// configuration or, with 422, the reason it was rejected.
+func (s extServer) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	dc, err := s.reload("admin")
+
+	if err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dc)
+}
+
+// reload reloads the dynamic configuration and logs the outcome.
+func (s extServer) reload(source string) (DynamicConfig, error) {
+	dc, err := s.dynamic.reload(source)
+	if err != nil {
+		s.Echo.Logger.Errorf("config reload from %s rejected, keeping previous configuration: %v", source, err)
+		return dc, err
+	}
+
+	s.colorer.Printf("[%s] config: reloaded from %s\n", s.colorer.Green("echoext"), s.colorer.Blue(source))
+
+	return dc, nil
+}
+
+// Reload reloads ReloadConfig.File, as the file watcher, signal and admin
+// endpoint do.
+func (s extServer) Reload() error {
+	if !s.config.ReloadConfig.enabled() {
+		return fmt.Errorf("config reload: ReloadConfig.File is not set")
+	}
+
+	_, err := s.reload("api")
+
+	return err
+}
+
+// DynamicConfig returns the effective dynamic configuration.
+func (s extServer) DynamicConfig() DynamicConfig {
+	return s.dynamic.current.Load().config
+}
+
+// watchReloadFile reloads the file whenever it changes, until stop closes.
+func (s extServer) watchReloadFile(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.config.ReloadConfig.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.dynamic.changed() {
+				_, _ = s.reload("file")
+			}
+		}
+	}
+}
+
+// Feature reports whether the feature toggle name is enabled in the
+// current DynamicConfig.
+func (c *context) Feature(name string) bool {
+	d, ok := c.parent.Get(dynamicKey).(*dynamicConfig)
+	if !ok {
+		return false
+	}
+
+	return d.current.Load().features[name]
+}
@@ -0,0 +1,193 @@
+package echoext
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeDynamic writes content to the dynamic configuration file at path.
+func writeDynamic(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadRollsBackInvalidConfig(t *testing.T) {
+	const initial = "cors_origins: [https://a.example.com]\nlog_level: warn\nfeatures: [beta]\n"
+
+	tests := []struct {
+		name      string
+		content   string
+		wantError bool
+		want      DynamicConfig
+	}{
+		{
+			name:    "valid",
+			content: "cors_origins: [https://b.example.com]\nlog_level: debug\nrate_limit:\n  limit: 10\n  window: 1s\n",
+			want: DynamicConfig{
+				CORSOrigins: []string{"https://b.example.com"},
+				RateLimit:   DynamicRateLimit{Limit: 10, Window: time.Second, Burst: 10},
+				LogLevel:    "debug",
+			},
+		},
+		{name: "unknown log level", content: "log_level: verbose\n", wantError: true},
+		{name: "negative limit", content: "rate_limit:\n  limit: -1\n", wantError: true},
+		{name: "window below 1ms", content: "rate_limit:\n  limit: 10\n  window: 1us\n", wantError: true},
+		{name: "malformed yaml", content: "cors_origins: [unterminated\n", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dynamic.yaml")
+			writeDynamic(t, path, initial)
+
+			srv := New(ServerConfig{
+				Environment:   Production,
+				MetricsConfig: MetricsConfig{Disabled: true},
+				ReloadConfig:  ReloadConfig{File: path, PollInterval: -1},
+			})
+			before := srv.DynamicConfig()
+
+			writeDynamic(t, path, tt.content)
+
+			err := srv.Reload()
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Reload() = %v, want error %v", err, tt.wantError)
+			}
+
+			got := srv.DynamicConfig()
+			want := tt.want
+			if tt.wantError {
+				want = before
+			}
+
+			if !slices.Equal(got.CORSOrigins, want.CORSOrigins) || got.RateLimit != want.RateLimit || got.LogLevel != want.LogLevel || !slices.Equal(got.Features, want.Features) {
+				t.Fatalf("DynamicConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestStartRejectsInvalidDynamicConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic.yaml")
+	writeDynamic(t, path, "log_level: verbose\n")
+
+	srv := New(ServerConfig{
+		Environment:   Production,
+		MetricsConfig: MetricsConfig{Disabled: true},
+		ReloadConfig:  ReloadConfig{File: path, PollInterval: -1},
+	})
+
+	if got := srv.DynamicConfig().LogLevel; got == "verbose" {
+		t.Fatalf("LogLevel = %q, want the static default", got)
+	}
+
+	if err := srv.Start(); err == nil {
+		t.Fatal("Start served with an invalid dynamic configuration")
+	}
+}
+
+func TestValidateSignals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic.yaml")
+	writeDynamic(t, path, "")
+
+	tests := []struct {
+		name      string
+		config    ServerConfig
+		wantError bool
+	}{
+		{
+			name: "reload and restart defaults",
+			config: ServerConfig{
+				ReloadConfig:  ReloadConfig{File: path},
+				RestartConfig: RestartConfig{Enabled: true},
+			},
+		},
+		{
+			name: "reload and restart share a signal",
+			config: ServerConfig{
+				ReloadConfig:  ReloadConfig{File: path},
+				RestartConfig: RestartConfig{Enabled: true, Signal: syscall.SIGUSR2},
+			},
+			wantError: true,
+		},
+		{
+			name: "toggle shares the reload signal",
+			config: ServerConfig{
+				ReloadConfig: ReloadConfig{File: path},
+				LogConfig:    LogConfig{ToggleSignal: syscall.SIGUSR2, EnableAdmin: true},
+			},
+			wantError: true,
+		},
+		{
+			name: "toggle unused while admin is disabled",
+			config: ServerConfig{
+				ReloadConfig: ReloadConfig{File: path},
+				LogConfig:    LogConfig{ToggleSignal: syscall.SIGUSR2},
+			},
+		},
+		{
+			name:   "restart signal unused while restart is disabled",
+			config: ServerConfig{RestartConfig: RestartConfig{Signal: syscall.SIGUSR1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validateSignals(); (err != nil) != tt.wantError {
+				t.Fatalf("validateSignals() = %v, want error %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestReloadEndpointRequiresProtectedMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic.yaml")
+	writeDynamic(t, path, "log_level: warn\n")
+
+	tests := []struct {
+		name    string
+		metrics MetricsConfig
+		want    int
+	}{
+		{name: "unprotected metrics", want: http.StatusNotFound},
+		{name: "allowlisted", metrics: MetricsConfig{AllowedCIDRs: []string{"192.0.2.0/24"}}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{
+				Environment:   Production,
+				MetricsConfig: tt.metrics,
+				ReloadConfig:  ReloadConfig{File: path, PollInterval: -1},
+			}).(extServer)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			ms, _, err := srv.listenMetrics(ln)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			ms.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("POST /admin/reload = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
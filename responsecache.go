@@ -0,0 +1,378 @@
+package echoext
+
+import (
+	"container/list"
+	stdcontext "context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CachedResponse is a response kept by a ResponseCacheStore.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Tags group entries for invalidation.
+	Tags []string
+}
+
+// ResponseCacheStore keeps cached responses. Implementations must be safe
+// for concurrent use.
+type ResponseCacheStore interface {
+	// Get returns the entry for key, reporting whether it was found.
+	Get(ctx stdcontext.Context, key string) (*CachedResponse, bool, error)
+	// Set stores an entry for ttl.
+	Set(ctx stdcontext.Context, key string, res *CachedResponse, ttl time.Duration) error
+	// InvalidateTags drops every entry carrying any of tags.
+	InvalidateTags(ctx stdcontext.Context, tags ...string) error
+}
+
+// ResponseCacheConfig configures the server-side response cache.
+type ResponseCacheConfig struct {
+	// Name labels the cache's metrics. Defaults to "default".
+	Name string
+	// TTL defaults to one minute.
+	TTL time.Duration
+	// QueryParams, when set, are the only query parameters that are part of
+	// the cache key. By default the whole query string is.
+	QueryParams []string
+	// Headers that are part of the cache key, such as a tenant header.
+	Headers []string
+	// Tags returns the tags attached to the cached response, used by
+	// InvalidateTags from write handlers.
+	Tags func(c Context) []string
+	// Store defaults to an in-memory LRU of 1000 entries.
+	Store ResponseCacheStore
+}
+
+func (c ResponseCacheConfig) withDefaults() ResponseCacheConfig {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+
+	if c.TTL <= 0 {
+		c.TTL = time.Minute
+	}
+
+	if c.Store == nil {
+		c.Store = NewLRUResponseCache(1000)
+	}
+
+	return c
+}
+
+// errCacheWaitAborted is returned to a request whose context ended while it
+// waited for a coalesced miss.
+var errCacheWaitAborted = newHTTPError(http.StatusServiceUnavailable, "request ended while waiting for the cached response")
+
+// ResponseCache returns a middleware caching successful GET responses.
+// Concurrent misses for the same key are coalesced so the handler runs once
+// and the others replay its response. Responses marked no-store or private,
+// setting cookies, using a CSP nonce or varying on headers outside the key
+// are not cached, and per-request headers are not stored. Authenticated
+// requests are keyed by subject; requests with an Authorization header but
+// no subject yet bypass the cache. Every response carries X-Cache: HIT or MISS, and lookups
+// are counted in http_response_cache_requests_total. Store errors are logged
+// and treated as misses.
+func ResponseCache(cfg ResponseCacheConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	flights := &cacheFlights{calls: map[string]*cacheFlight{}}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet {
+				return next(c)
+			}
+
+			// Authentication running after the cache would make the
+			// response depend on a caller the key does not know.
+			if c.Subject() == "" && req.Header.Get(echo.HeaderAuthorization) != "" {
+				return next(c)
+			}
+
+			ctx := req.Context()
+			key := cfg.key(c)
+
+			cached, ok, err := cfg.Store.Get(ctx, key)
+			if err != nil {
+				c.Logger().Errorf("response cache store: %v", err)
+			}
+
+			if ok {
+				responseCacheRequests.WithLabelValues(cfg.Name, "hit").Inc()
+				c.Response().Header().Set("X-Cache", "HIT")
+				return writeStoredResponse(c, cached.StatusCode, cached.Header, cached.Body)
+			}
+
+			f, leader := flights.join(key)
+			if !leader {
+				select {
+				case <-f.done:
+				case <-ctx.Done():
+					return errCacheWaitAborted
+				}
+
+				if f.res != nil {
+					responseCacheRequests.WithLabelValues(cfg.Name, "coalesced").Inc()
+					c.Response().Header().Set("X-Cache", "HIT")
+					return writeStoredResponse(c, f.res.StatusCode, f.res.Header, f.res.Body)
+				}
+
+				// The leader's response was not cacheable; handle this
+				// request on its own.
+				responseCacheRequests.WithLabelValues(cfg.Name, "miss").Inc()
+				c.Response().Header().Set("X-Cache", "MISS")
+				return next(c)
+			}
+
+			var res *CachedResponse
+			defer func() { flights.finish(key, f, res) }()
+
+			responseCacheRequests.WithLabelValues(cfg.Name, "miss").Inc()
+			c.Response().Header().Set("X-Cache", "MISS")
+
+			r := c.Response()
+			bw := &bufferWriter{ResponseWriter: r.Writer}
+			r.Writer = bw
+			defer func() { r.Writer = bw.ResponseWriter }()
+
+			err = next(c)
+			if err != nil || bw.passthrough || bw.status != http.StatusOK || !cfg.cacheable(c, r.Header()) {
+				bw.release()
+				return err
+			}
+
+			header := storableHeader(r.Header())
+
+			res = &CachedResponse{
+				StatusCode: bw.status,
+				Header:     header,
+				Body:       slices.Clone(bw.buf.Bytes()),
+			}
+
+			if cfg.Tags != nil {
+				res.Tags = cfg.Tags(c)
+			}
+
+			if sErr := cfg.Store.Set(ctx, key, res, cfg.TTL); sErr != nil {
+				c.Logger().Errorf("response cache store: %v", sErr)
+			}
+
+			bw.release()
+
+			return nil
+		}
+	}
+}
+
+// key builds the cache key from the path, the selected query parameters and
+// headers, and the authenticated subject.
+func (c ResponseCacheConfig) key(ctx Context) string {
+	req := ctx.Request()
+
+	var b strings.Builder
+	// Every part is escaped so a value cannot forge the separators of the
+	// parts after it.
+	b.WriteString(req.Method + " " + url.PathEscape(req.URL.Path) + "?")
+
+	query := req.URL.Query()
+	if len(c.QueryParams) > 0 {
+		selected := url.Values{}
+		for _, p := range c.QueryParams {
+			if v, ok := query[p]; ok {
+				selected[p] = v
+			}
+		}
+		query = selected
+	}
+
+	// Encode sorts by key, making the key independent of parameter order.
+	b.WriteString(query.Encode())
+
+	for _, h := range c.Headers {
+		b.WriteString("|" + url.QueryEscape(h) + "=" + url.QueryEscape(req.Header.Get(h)))
+	}
+
+	if sub := ctx.Subject(); sub != "" {
+		b.WriteString("|sub=" + url.QueryEscape(sub))
+	}
+
+	return b.String()
+}
+
+// cacheable reports whether the response can be replayed to other requests
+// with the same key. The response's own Cache-Control must allow a shared
+// cache, it must not set cookies or embed a CSP nonce, and it must not vary
+// on headers outside the key. Accept-Encoding and Origin are allowed since
+// compression and CORS run outside the cache.
+func (c ResponseCacheConfig) cacheable(ctx Context, h http.Header) bool {
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+
+	if len(h.Values("Set-Cookie")) > 0 || ctx.Get(cspNonceKey) != nil {
+		return false
+	}
+
+	for _, f := range h.Values("Vary") {
+		for _, v := range strings.Split(f, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" {
+				return false
+			}
+
+			if !strings.EqualFold(v, echo.HeaderAcceptEncoding) && !strings.EqualFold(v, echo.HeaderOrigin) && !slices.ContainsFunc(c.Headers, func(k string) bool {
+				return strings.EqualFold(k, v)
+			}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// cacheFlights coalesces concurrent misses for the same key.
+type cacheFlights struct {
+	mu    sync.Mutex
+	calls map[string]*cacheFlight
+}
+
+type cacheFlight struct {
+	done chan struct{}
+	res  *CachedResponse
+}
+
+// join returns the flight for key, reporting whether the caller leads it.
+func (f *cacheFlights) join(key string) (*cacheFlight, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if call, ok := f.calls[key]; ok {
+		return call, false
+	}
+
+	call := &cacheFlight{done: make(chan struct{})}
+	f.calls[key] = call
+
+	return call, true
+}
+
+// finish publishes the leader's response, nil when not cacheable, to the
+// waiting followers.
+func (f *cacheFlights) finish(key string, call *cacheFlight, res *CachedResponse) {
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+
+	call.res = res
+	close(call.done)
+}
+
+// LRUResponseCache is an in-memory ResponseCacheStore evicting the least
+// recently used entries beyond its capacity.
+type LRUResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	tags     map[string]map[string]struct{}
+}
+
+type lruEntry struct {
+	key     string
+	res     *CachedResponse
+	expires time.Time
+}
+
+// NewLRUResponseCache creates a cache holding at most capacity entries.
+func NewLRUResponseCache(capacity int) *LRUResponseCache {
+	return &LRUResponseCache{
+		capacity: max(1, capacity),
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+	}
+}
+
+// Get implements ResponseCacheStore.
+func (s *LRUResponseCache) Get(_ stdcontext.Context, key string) (*CachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+
+	return e.res, true, nil
+}
+
+// Set implements ResponseCacheStore.
+func (s *LRUResponseCache) Set(_ stdcontext.Context, key string, res *CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, res: res, expires: time.Now().Add(ttl)})
+	for _, tag := range res.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]struct{}{}
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+
+	return nil
+}
+
+// InvalidateTags implements ResponseCacheStore.
+func (s *LRUResponseCache) InvalidateTags(_ stdcontext.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if el, ok := s.entries[key]; ok {
+				s.remove(el)
+			}
+		}
+	}
+
+	return nil
+}
+
+// remove drops an entry and its tag index. Callers must hold s.mu.
+func (s *LRUResponseCache) remove(el *list.Element) {
+	e := s.order.Remove(el).(*lruEntry)
+	delete(s.entries, e.key)
+
+	for _, tag := range e.res.Tags {
+		delete(s.tags[tag], e.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
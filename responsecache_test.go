@@ -0,0 +1,198 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// respond sets the handler's response headers.
+		respond func(h http.Header)
+		cfg     ResponseCacheConfig
+		// request sets the headers of the nth request.
+		request   func(n int, h http.Header)
+		wantCalls int
+		// wantHeader lists headers the replayed response must have, with ""
+		// meaning absent.
+		wantHeader map[string]string
+	}{
+		{
+			name: "per-request headers stripped",
+			respond: func(h http.Header) {
+				h.Set("RateLimit-Remaining", "9")
+				h.Set("X-Request-ID", "first")
+				h.Set("X-Menu", "lunch")
+			},
+			wantCalls:  1,
+			wantHeader: map[string]string{"X-Cache": "HIT", "RateLimit-Remaining": "", "X-Request-ID": "", "X-Menu": "lunch"},
+		},
+		{
+			name:       "connection-listed headers stripped",
+			respond:    func(h http.Header) { h.Set("Connection", "X-Hop"); h.Set("X-Hop", "1") },
+			wantCalls:  1,
+			wantHeader: map[string]string{"X-Cache": "HIT", "X-Hop": ""},
+		},
+		{
+			name:       "set-cookie not cached",
+			respond:    func(h http.Header) { h.Set("Set-Cookie", "session=abc") },
+			wantCalls:  2,
+			wantHeader: map[string]string{"X-Cache": "MISS"},
+		},
+		{
+			name:       "private not cached",
+			respond:    func(h http.Header) { h.Set("Cache-Control", "private") },
+			wantCalls:  2,
+			wantHeader: map[string]string{"X-Cache": "MISS"},
+		},
+		{
+			name:      "vary outside key not cached",
+			respond:   func(h http.Header) { h.Set("Vary", "Accept-Language") },
+			wantCalls: 2,
+		},
+		{
+			name:       "vary inside key cached",
+			respond:    func(h http.Header) { h.Set("Vary", "Accept-Encoding, X-Tenant") },
+			cfg:        ResponseCacheConfig{Headers: []string{"X-Tenant"}},
+			wantCalls:  1,
+			wantHeader: map[string]string{"X-Cache": "HIT"},
+		},
+		{
+			name:      "keyed by subject",
+			request:   func(n int, h http.Header) { h.Set("X-Subject", []string{"alice", "bob"}[n]) },
+			wantCalls: 2,
+		},
+		{
+			name:       "same subject shares",
+			request:    func(_ int, h http.Header) { h.Set("X-Subject", "alice") },
+			wantCalls:  1,
+			wantHeader: map[string]string{"X-Cache": "HIT"},
+		},
+		{
+			name: "header cannot forge the subject",
+			cfg:  ResponseCacheConfig{Headers: []string{"X-Tenant"}},
+			request: func(n int, h http.Header) {
+				if n == 0 {
+					h.Set("X-Tenant", "t1")
+					h.Set("X-Subject", "alice")
+				} else {
+					h.Set("X-Tenant", "t1|sub=alice")
+				}
+			},
+			wantCalls:  2,
+			wantHeader: map[string]string{"X-Cache": "MISS"},
+		},
+		{
+			name:       "authorization without subject bypasses",
+			request:    func(_ int, h http.Header) { h.Set("Authorization", "Bearer t") },
+			wantCalls:  2,
+			wantHeader: map[string]string{"X-Cache": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+
+			srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("menu", func(g *Group) {
+				g.GET("", func(c Context) error {
+					calls++
+					if tt.respond != nil {
+						tt.respond(c.Response().Header())
+					}
+
+					return c.String(http.StatusOK, "menu")
+				}, fakeAuth, ResponseCache(tt.cfg))
+			})
+
+			var last *httptest.ResponseRecorder
+			for n := range 2 {
+				req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+				if tt.request != nil {
+					tt.request(n, req.Header)
+				}
+
+				last = httptest.NewRecorder()
+				srv.Engine().ServeHTTP(last, req)
+
+				if last.Code != http.StatusOK || last.Body.String() != "menu" {
+					t.Fatalf("request %d = %d %q", n, last.Code, last.Body)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+
+			for k, v := range tt.wantHeader {
+				if got := last.Header().Get(k); got != v {
+					t.Errorf("header %s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCacheFollowerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("slow", func(g *Group) {
+		g.GET("", func(c Context) error {
+			close(started)
+			<-release
+			return c.String(http.StatusOK, "done")
+		}, ResponseCache(ResponseCacheConfig{}))
+	})
+
+	go srv.Engine().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+	defer close(release)
+
+	// The follower's own deadline passes while the leader is still running.
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+
+	srv.Engine().ServeHTTP(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("follower status = %d, want 503 (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestResponseCacheWithCORS(t *testing.T) {
+	calls := 0
+
+	srv := New(ServerConfig{Environment: Staging, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("menu", func(g *Group) {
+		g.GET("", func(c Context) error {
+			calls++
+			return c.String(http.StatusOK, "menu")
+		}, ResponseCache(ResponseCacheConfig{}))
+	})
+
+	for _, origin := range []string{"https://a.example.com", "https://b.example.com"} {
+		req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+		req.Header.Set("Origin", origin)
+
+		rec := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin && got != "*" {
+			t.Fatalf("Access-Control-Allow-Origin = %q for origin %s", got, origin)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
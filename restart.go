@@ -0,0 +1,192 @@
+package echoext
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// restartListenersEnv names the listeners a restarted process inherits,
+	// in file descriptor order from 3.
+	restartListenersEnv = "ECHOEXT_LISTENERS"
+	// restartReadyFDEnv is the descriptor the restarted process reports
+	// readiness on.
+	restartReadyFDEnv = "ECHOEXT_READY_FD"
+)
+
+// RestartConfig enables zero-downtime binary upgrades. On Signal, Start
+// re-executes the current binary, passing it the listening sockets, waits
+// for it to report readiness and then drains and exits.
+type RestartConfig struct {
+	Enabled bool
+	// Signal triggers the restart. Defaults to SIGHUP.
+	Signal os.Signal
+	// ReadyTimeout bounds how long the new process may take to start
+	// serving. Defaults to 30 seconds.
+	ReadyTimeout time.Duration
+}
+
+func (c *RestartConfig) signal() os.Signal {
+	if c.Signal == nil {
+		return syscall.SIGHUP
+	}
+
+	return c.Signal
+}
+
+func (c *RestartConfig) readyTimeout() time.Duration {
+	if c.ReadyTimeout <= 0 {
+		return 30 * time.Second
+	}
+
+	return c.ReadyTimeout
+}
+
+// namedListener is a listener handed to or inherited from another process.
+type namedListener struct {
+	name string
+	ln   net.Listener
+}
+
+// inheritance holds what a restarted process received from its parent.
+type inheritance struct {
+	listeners map[string]net.Listener
+	ready     *os.File
+}
+
+// inherit picks up the listeners passed by a parent process during a
+// restart. It returns nil when the process was started normally.
+func inherit() (*inheritance, error) {
+	names := os.Getenv(restartListenersEnv)
+	if names == "" {
+		return nil, nil
+	}
+
+	readyFD := os.Getenv(restartReadyFDEnv)
+	os.Unsetenv(restartListenersEnv)
+	os.Unsetenv(restartReadyFDEnv)
+
+	inh := &inheritance{listeners: map[string]net.Listener{}}
+	for i, name := range strings.Split(names, ":") {
+		f := os.NewFile(uintptr(systemdFirstFD+i), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("restart: inherit %s listener: %w", name, err)
+		}
+
+		inh.listeners[name] = ln
+	}
+
+	if fd, err := strconv.Atoi(readyFD); err == nil {
+		inh.ready = os.NewFile(uintptr(fd), "ready")
+	}
+
+	return inh, nil
+}
+
+// listener returns the inherited listener called name, or nil.
+func (i *inheritance) listener(name string) net.Listener {
+	if i == nil {
+		return nil
+	}
+
+	return i.listeners[name]
+}
+
+// signalReady tells the parent the process is serving.
+func (i *inheritance) signalReady() {
+	if i == nil || i.ready == nil {
+		return
+	}
+
+	_, _ = i.ready.Write([]byte{1})
+	i.ready.Close()
+}
+
+// handoff starts a new copy of the binary with the listeners and waits for
+// it to become ready. It returns the new process's PID.
+func handoff(listeners []namedListener, timeout time.Duration) (int, error) {
+	names := make([]string, 0, len(listeners))
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range listeners {
+		fl, ok := l.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return 0, fmt.Errorf("restart: %s listener cannot be handed off", l.name)
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			return 0, fmt.Errorf("restart: %s listener: %w", l.name, err)
+		}
+
+		names = append(names, l.name)
+		files = append(files, f)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+	defer r.Close()
+	files = append(files, w)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		restartListenersEnv+"="+strings.Join(names, ":"),
+		restartReadyFDEnv+"="+strconv.Itoa(systemdFirstFD+len(files)-1),
+	)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+
+	// Only the child may hold the write end, so a crash reads as EOF.
+	w.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-ready:
+	case <-timer.C:
+		err = errors.New("timed out")
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		go cmd.Wait()
+
+		return 0, fmt.Errorf("restart: new process did not become ready: %w", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
@@ -0,0 +1,143 @@
+package echoext
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// restartChildEnv tells a test binary started by handoff how to behave as
+// the new process: "serve", "crash" or "hang".
+const restartChildEnv = "ECHOEXT_TEST_RESTART_CHILD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(restartListenersEnv) != "" {
+		restartChild()
+	}
+
+	os.Exit(m.Run())
+}
+
+// restartChild plays the new process of a handoff: it inherits the main
+// listener, answers with its PID and reports readiness, unless told to crash
+// or hang first.
+func restartChild() {
+	mode := os.Getenv(restartChildEnv)
+
+	inh, err := inherit()
+	if err != nil || inh.listener("main") == nil {
+		os.Exit(2)
+	}
+
+	switch mode {
+	case "crash":
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+		os.Exit(1)
+	}
+
+	go func() {
+		_ = http.Serve(inh.listener("main"), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, strconv.Itoa(os.Getpid()))
+		}))
+	}()
+
+	inh.signalReady()
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestRestartHandoff(t *testing.T) {
+	t.Setenv(restartChildEnv, "serve")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := handoff([]namedListener{{name: "main", ln: ln}}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if p, err := os.FindProcess(pid); err == nil {
+			_ = p.Kill()
+		}
+	})
+
+	// The old process stops accepting; the new one serves the same socket.
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	res, err := client.Get("http://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if got := strings.TrimSpace(string(body)); got != strconv.Itoa(pid) {
+		t.Fatalf("served by PID %s, want the new process %d", got, pid)
+	}
+}
+
+func TestRestartHandoffNotReady(t *testing.T) {
+	tests := []struct {
+		mode    string
+		timeout time.Duration
+		want    string
+	}{
+		{mode: "crash", timeout: 10 * time.Second, want: "EOF"},
+		{mode: "hang", timeout: 200 * time.Millisecond, want: "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv(restartChildEnv, tt.mode)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			if _, err := handoff([]namedListener{{name: "main", ln: ln}}, tt.timeout); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("handoff() = %v, want an error containing %q", err, tt.want)
+			}
+
+			// The old process keeps its listener.
+			go func() {
+				if c, err := ln.Accept(); err == nil {
+					c.Close()
+				}
+			}()
+
+			c, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatalf("old listener no longer accepts: %v", err)
+			}
+			c.Close()
+		})
+	}
+}
+
+func TestInheritWithoutParent(t *testing.T) {
+	inh, err := inherit()
+	if inh != nil || err != nil {
+		t.Fatalf("inherit() = %v, %v, want nothing inherited", inh, err)
+	}
+
+	if inh.listener("main") != nil {
+		t.Fatal("listener() on no inheritance returned a listener")
+	}
+
+	inh.signalReady()
+}
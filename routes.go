@@ -0,0 +1,65 @@
+package echoext
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteInfo describes a route registered through a Group.
+type RouteInfo struct {
+	Method string
+	Path   string
+	// Authorization lists the requirements enforced on the route, in the
+	// order they were added with Group.Require.
+	Authorization []Requirement
+	// Timeout is the deadline set with Group.WithTimeout, or zero.
+	Timeout time.Duration
+}
+
+// String renders the route for the startup route table.
+func (r RouteInfo) String() string {
+	s := r.Method + " " + r.Path
+	if r.Timeout > 0 {
+		s += " (timeout " + r.Timeout.String() + ")"
+	}
+
+	if len(r.Authorization) == 0 {
+		return s
+	}
+
+	reqs := make([]string, len(r.Authorization))
+	for i, req := range r.Authorization {
+		reqs[i] = req.String()
+	}
+
+	return s + " [" + strings.Join(reqs, "; ") + "]"
+}
+
+// routeTable records the routes registered on a server.
+type routeTable struct {
+	mu     sync.RWMutex
+	routes []RouteInfo
+}
+
+func (t *routeTable) add(r RouteInfo) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.routes = append(t.routes, r)
+}
+
+func (t *routeTable) list() []RouteInfo {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return append([]RouteInfo(nil), t.routes...)
+}
@@ -0,0 +1,365 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// redacted replaces secret values wherever they are printed or marshaled.
+const redacted = "[REDACTED]"
+
+// secretsDir is where relative file:// references are looked up, the
+// directory Docker and Kubernetes mount secrets into.
+const secretsDir = "/run/secrets"
+
+// SecretProvider resolves secret references of one scheme, such as a vault.
+// Implementations must be safe for concurrent use.
+type SecretProvider interface {
+	// Resolve returns the current value of ref, the reference without its
+	// "scheme://" prefix.
+	Resolve(ctx stdcontext.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider.
+type SecretProviderFunc func(ctx stdcontext.Context, ref string) (string, error)
+
+func (f SecretProviderFunc) Resolve(ctx stdcontext.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// FileSecretProvider reads secrets from files, trimming trailing newlines.
+// Relative paths are resolved against /run/secrets, so file://db_password
+// reads /run/secrets/db_password.
+var FileSecretProvider = SecretProviderFunc(func(_ stdcontext.Context, ref string) (string, error) {
+	path := ref
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(secretsDir, path)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+})
+
+// EnvSecretProvider reads secrets from environment variables.
+var EnvSecretProvider = SecretProviderFunc(func(_ stdcontext.Context, ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+
+	return v, nil
+})
+
+// Secret is a credential that never shows up in logs or printed config.
+// String, GoString, MarshalText, MarshalJSON and LogValue all return
+// "[REDACTED]"; Value returns the plaintext. Set from a string, such as by
+// LoadConfig, the string is either a reference resolved through
+// DefaultSecretStore (file:///run/secrets/db, env://DB_PASSWORD or a
+// registered provider scheme) or, for any other string, the literal value.
+// Secrets resolved through a store follow its rotations.
+type Secret struct {
+	e *secretEntry
+}
+
+// NewSecret returns a Secret holding the literal value.
+func NewSecret(value string) Secret {
+	e := &secretEntry{}
+	e.value.Store(&value)
+
+	return Secret{e: e}
+}
+
+// Value returns the current plaintext value, or "" for the zero Secret.
+func (s Secret) Value() string {
+	if s.e == nil {
+		return ""
+	}
+
+	return *s.e.value.Load()
+}
+
+// IsZero reports whether the secret was never set.
+func (s Secret) IsZero() bool {
+	return s.e == nil
+}
+
+// Ref returns the reference the secret was resolved from, or "" for
+// literal values.
+func (s Secret) Ref() string {
+	if s.e == nil {
+		return ""
+	}
+
+	return s.e.ref
+}
+
+// OnRotate registers fn to be called with the secret after its store
+// observes a new value. Literal secrets never rotate.
+func (s Secret) OnRotate(fn func(Secret)) {
+	if s.e == nil {
+		return
+	}
+
+	s.e.mu.Lock()
+	s.e.callbacks = append(s.e.callbacks, fn)
+	s.e.mu.Unlock()
+}
+
+func (s Secret) String() string {
+	if s.e == nil {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// LogValue redacts the secret in log/slog output.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalText resolves text through DefaultSecretStore.
+func (s *Secret) UnmarshalText(text []byte) error {
+	secret, err := DefaultSecretStore.Resolve(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = secret
+
+	return nil
+}
+
+// secretEntry is a resolved secret shared by every Secret with the same
+// reference.
+type secretEntry struct {
+	ref   string
+	value atomic.Pointer[string]
+
+	mu        sync.Mutex
+	callbacks []func(Secret)
+}
+
+// SecretStoreConfig configures a SecretStore.
+type SecretStoreConfig struct {
+	// RefreshInterval is how often resolved references are read again to
+	// pick up rotations. Defaults to one minute; negative disables refresh.
+	RefreshInterval time.Duration
+	// Timeout bounds each resolution. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+func (c SecretStoreConfig) withDefaults() SecretStoreConfig {
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = time.Minute
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+
+	return c
+}
+
+// SecretStore resolves secret references through providers keyed by
+// scheme and caches them in memory, so every Secret with the same reference
+// shares one value. While refreshing, started with StartRefresh, the store
+// re-reads resolved references every RefreshInterval and runs the OnRotate
+// callbacks when they change.
+type SecretStore struct {
+	cfg SecretStoreConfig
+
+	mu        sync.Mutex
+	providers map[string]SecretProvider
+	entries   map[string]*secretEntry
+	// refreshers counts the StartRefresh callers that have not stopped;
+	// stopRefresh ends the refresh loop they share.
+	refreshers  int
+	stopRefresh chan struct{}
+}
+
+// DefaultSecretStore resolves the secrets set from text, including those
+// loaded by LoadConfig. Register vault providers on it before loading.
+// Server.Start refreshes it while serving.
+var DefaultSecretStore = NewSecretStore()
+
+// NewSecretStore returns a store with the file and env providers
+// registered.
+func NewSecretStore(cfg ...SecretStoreConfig) *SecretStore {
+	c := SecretStoreConfig{}
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+
+	return &SecretStore{
+		cfg: c.withDefaults(),
+		providers: map[string]SecretProvider{
+			"file": FileSecretProvider,
+			"env":  EnvSecretProvider,
+		},
+		entries: map[string]*secretEntry{},
+	}
+}
+
+// Register makes references starting with "scheme://" resolve through p.
+func (s *SecretStore) Register(scheme string, p SecretProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.providers[scheme] = p
+}
+
+// Resolve returns the secret for ref. References with a registered scheme
+// are resolved once and then served from memory; anything else, including
+// URLs with other schemes, is a literal value.
+func (s *SecretStore) Resolve(ref string) (Secret, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+
+	s.mu.Lock()
+	p, registered := s.providers[scheme]
+	e, cached := s.entries[ref]
+	s.mu.Unlock()
+
+	if !ok || !registered {
+		return NewSecret(ref), nil
+	}
+
+	if cached {
+		return Secret{e: e}, nil
+	}
+
+	value, err := s.resolve(p, rest)
+	if err != nil {
+		return Secret{}, fmt.Errorf("secret %s: %w", ref, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another caller may have resolved the same reference meanwhile.
+	if e, cached := s.entries[ref]; cached {
+		return Secret{e: e}, nil
+	}
+
+	e = &secretEntry{ref: ref}
+	e.value.Store(&value)
+	s.entries[ref] = e
+
+	return Secret{e: e}, nil
+}
+
+func (s *SecretStore) resolve(p SecretProvider, ref string) (string, error) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	return p.Resolve(ctx, ref)
+}
+
+// StartRefresh refreshes the store every RefreshInterval until every caller
+// has called the returned stop function. It does nothing when refresh is
+// disabled.
+func (s *SecretStore) StartRefresh() (stop func()) {
+	if s.cfg.RefreshInterval <= 0 {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refreshers == 0 {
+		s.stopRefresh = make(chan struct{})
+		go s.refreshLoop(s.stopRefresh)
+	}
+
+	s.refreshers++
+
+	return sync.OnceFunc(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.refreshers--; s.refreshers == 0 {
+			close(s.stopRefresh)
+		}
+	})
+}
+
+func (s *SecretStore) refreshLoop(stop chan struct{}) {
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Refresh()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Refresh reads every cached reference again and runs the OnRotate
+// callbacks of those that changed. A failed read keeps the previous value.
+func (s *SecretStore) Refresh() error {
+	s.mu.Lock()
+	entries := make([]*secretEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, e := range entries {
+		scheme, rest, _ := strings.Cut(e.ref, "://")
+
+		s.mu.Lock()
+		p := s.providers[scheme]
+		s.mu.Unlock()
+
+		value, err := s.resolve(p, rest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("secret %s: %w", e.ref, err))
+			continue
+		}
+
+		if value == *e.value.Load() {
+			continue
+		}
+
+		e.value.Store(&value)
+
+		e.mu.Lock()
+		callbacks := append([]func(Secret){}, e.callbacks...)
+		e.mu.Unlock()
+
+		for _, fn := range callbacks {
+			fn(Secret{e: e})
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSecretStoreStartRefresh(t *testing.T) {
+	var value atomic.Value
+	value.Store("v1")
+
+	store := NewSecretStore(SecretStoreConfig{RefreshInterval: 5 * time.Millisecond})
+	store.Register("test", SecretProviderFunc(func(stdcontext.Context, string) (string, error) {
+		return value.Load().(string), nil
+	}))
+
+	secret, err := store.Resolve("test://key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value.Store("v2")
+	time.Sleep(30 * time.Millisecond)
+
+	if got := secret.Value(); got != "v1" {
+		t.Fatalf("refreshed to %q before StartRefresh", got)
+	}
+
+	stopA := store.StartRefresh()
+	stopB := store.StartRefresh()
+
+	waitFor(t, func() bool { return secret.Value() == "v2" })
+
+	// The loop keeps running until every caller has stopped.
+	stopA()
+	stopA()
+	value.Store("v3")
+	waitFor(t, func() bool { return secret.Value() == "v3" })
+
+	stopB()
+	time.Sleep(20 * time.Millisecond)
+	value.Store("v4")
+	time.Sleep(30 * time.Millisecond)
+
+	if got := secret.Value(); got != "v3" {
+		t.Fatalf("refreshed to %q after every stop", got)
+	}
+}
+
+func TestStartRejectsUnresolvedSwaggerCredentials(t *testing.T) {
+	t.Setenv("SWAGGER_CREDENTIALS", "env://ECHOEXT_TEST_MISSING_SWAGGER_CREDENTIALS")
+
+	srv := New(ServerConfig{Environment: Local, MetricsConfig: MetricsConfig{Disabled: true}})
+	if err := srv.Start(); err == nil {
+		t.Fatal("Start served with unresolved Swagger credentials")
+	}
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatal("condition not met within 1s")
+}
+
+func TestSwaggerBasicAuth(t *testing.T) {
+	srv := New(ServerConfig{
+		Environment:   Local,
+		SwaggerConfig: SwaggerConfig{Credentials: NewSecret("docs:s3cret")},
+		MetricsConfig: MetricsConfig{Disabled: true},
+	})
+
+	tests := []struct {
+		name       string
+		user, pass string
+		wantStatus int
+	}{
+		{name: "valid", user: "docs", pass: "s3cret", wantStatus: http.StatusOK},
+		{name: "wrong password", user: "docs", pass: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "wrong user", user: "admin", pass: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "empty", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/docs/index.html", nil)
+			req.SetBasicAuth(tt.user, tt.pass)
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
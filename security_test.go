@@ -0,0 +1,127 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	defaults := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Permissions-Policy":      "camera=(), geolocation=(), microphone=()",
+		"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+	}
+
+	tests := []struct {
+		name     string
+		server   SecurityConfig
+		route    []MiddlewareFunc
+		url      string
+		handler  HandlerFunc
+		want     map[string]string
+		wantHSTS string
+	}{
+		{name: "defaults", want: defaults},
+		{name: "defaults over https", url: "https://example.com/api", want: defaults, wantHSTS: "max-age=31536000"},
+		{
+			name:     "hsts options",
+			server:   SecurityConfig{HSTSIncludeSubdomains: true, HSTSPreload: true},
+			url:      "https://example.com/api",
+			wantHSTS: "max-age=31536000; includeSubDomains; preload",
+		},
+		{name: "hsts disabled", server: SecurityConfig{HSTSMaxAge: -1}, url: "https://example.com/api"},
+		{
+			name:   "omitted headers",
+			server: SecurityConfig{FrameOptions: OmitHeader, ContentSecurityPolicy: OmitHeader},
+			want:   map[string]string{"X-Frame-Options": "", "Content-Security-Policy": "", "X-Content-Type-Options": "nosniff"},
+		},
+		{
+			name:  "route overrides server",
+			route: []MiddlewareFunc{SecurityHeaders(SecurityConfig{FrameOptions: "SAMEORIGIN", ContentSecurityPolicy: "default-src 'self'"})},
+			want:  map[string]string{"X-Frame-Options": "SAMEORIGIN", "Content-Security-Policy": "default-src 'self'"},
+		},
+		{
+			name: "handler header kept",
+			handler: func(c Context) error {
+				c.Response().Header().Set("Content-Security-Policy", "sandbox")
+				return c.NoContent(http.StatusNoContent)
+			},
+			want: map[string]string{"Content-Security-Policy": "sandbox"},
+		},
+		{name: "disabled", server: SecurityConfig{Disabled: true}, want: map[string]string{"X-Frame-Options": "", "Content-Security-Policy": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler
+			if handler == nil {
+				handler = func(c Context) error { return c.JSON(http.StatusOK, map[string]string{"status": "ok"}) }
+			}
+
+			url := tt.url
+			if url == "" {
+				url = "/api"
+			}
+
+			srv := New(ServerConfig{Environment: Production, SecurityConfig: tt.server, MetricsConfig: MetricsConfig{Disabled: true}})
+			srv.Group("api", func(g *Group) {
+				g.GET("", handler, tt.route...)
+			})
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+			for k, want := range tt.want {
+				if got := rec.Header().Get(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tt.wantHSTS {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tt.wantHSTS)
+			}
+		})
+	}
+}
+
+func TestCSPNonce(t *testing.T) {
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("pages", func(g *Group) {
+		g.GET("/home", func(c Context) error {
+			return c.HTML(http.StatusOK, c.CSPNonce())
+		})
+		g.GET("/data", func(c Context) error {
+			return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		})
+	}, SecurityHeaders(SecurityConfig{ContentSecurityPolicy: "default-src 'self'; script-src 'self' {nonce}"}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	seen := map[string]bool{}
+	for range 3 {
+		rec := get("/pages/home")
+
+		nonce := rec.Body.String()
+		if nonce == "" || seen[nonce] {
+			t.Fatalf("nonce %q is empty or reused", nonce)
+		}
+		seen[nonce] = true
+
+		want := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'"
+		if got := rec.Header().Get("Content-Security-Policy"); got != want {
+			t.Fatalf("Content-Security-Policy = %q, want %q", got, want)
+		}
+	}
+
+	if got := get("/pages/data").Header().Get("Content-Security-Policy"); got != "default-src 'self'; script-src 'self'" {
+		t.Fatalf("Content-Security-Policy on JSON = %q, want the placeholder dropped", got)
+	}
+}
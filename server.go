@@ -2,7 +2,10 @@ package echoext
 
 import (
 	stdcontext "context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,7 +16,6 @@ import (
 	"github.com/labstack/gommon/color"
 	echoSwagger "github.com/swaggo/echo-swagger"
 
-	validator "github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	emiddleware "github.com/labstack/echo/v4/middleware"
 )
@@ -30,9 +32,26 @@ const (
 )
 
 type Server interface {
+	Router
 	Start() error
-	Group(string, setupfn, ...MiddlewareFunc) *Group
-	Engine() *echo.Echo
+	Environment() Environment
+	// Reload reloads ReloadConfig.File. Invalid configurations are rejected
+	// and the current one stays in effect.
+	Reload() error
+	// DynamicConfig returns the dynamic configuration in effect.
+	DynamicConfig() DynamicConfig
+	// LogLevel returns the log level in effect and SetLogLevel changes it
+	// until the next reload.
+	LogLevel() string
+	SetLogLevel(level string) error
+	// AddDebugRule logs the request and response bodies of traffic matching
+	// rule for d.
+	AddDebugRule(rule DebugRule, d time.Duration) (DebugRule, error)
+	DebugRules() []DebugRule
+	RemoveDebugRule(id string) bool
+	// Named returns the additional server declared in ServerConfig.Servers
+	// under name. It panics for unknown names.
+	Named(name string) Router
 }
 
 type Mountable interface {
@@ -43,9 +62,15 @@ type extServer struct {
 	*echo.Echo
 	config  ServerConfig
 	colorer *color.Color
-	appEnv  string
+	env     Environment
 	root    *Group
 	mode    string
+	routes  *routeTable
+	name    string
+	servers []extServer
+	dynamic *dynamicConfig
+	// err holds the configuration errors found by New, returned by Start.
+	err error
 }
 
 func New(cl ...ServerConfig) Server {
@@ -54,69 +79,78 @@ func New(cl ...ServerConfig) Server {
 		c = cl[0]
 	}
 
-	s := echo.New()
-	s.HideBanner = true
-
-	s.Validator = &Validator{
-		v: validator.New(
-			validator.WithRequiredStructEnabled(),
-		),
-	}
-
 	c.HealthcheckPath = c.escapeHealthcheckSuffix()
 	c.PathPrefix = c.escapePrefix()
+	c.Environment = c.environment()
 
-	s.Use(CustomLogger(c))
-	s.Use(CustomRecovery)
-	s.Use(CustomCORS(c))
+	cfgErr := c.validate()
 
-	if !c.MetricsConfig.Disabled {
-		s.Use(metricsMiddleware)
-	}
+	dynamic, err := newDynamicConfig(c)
+	cfgErr = errors.Join(cfgErr, err)
 
-	root := s.Group(c.PathPrefix)
-	root.GET(c.escapeHealthcheckSuffix(), func(ctx echo.Context) error {
-		return ctx.JSON(http.StatusOK, echo.Map{"status": "ok"})
-	})
+	s, root := newEngine(c, "main", true, dynamic)
+	routes := &routeTable{}
+	profile := c.profile()
 
 	colorer := color.New()
-	env := os.Getenv("APP_ENV")
-	if env == "" {
-		env = "local"
-	}
-
-	colorer.Printf("[%s] app enviroment: %s\n", colorer.Green("echoext"), colorer.Blue(env))
+	colorer.Printf("[%s] app enviroment: %s\n", colorer.Green("echoext"), colorer.Blue(c.Environment))
 
 	colorer.Printf("[%s] server prefix: %s\n", colorer.Green("echoext"), colorer.Blue(c.PathPrefix))
 	colorer.Printf("[%s] healthcheck path: %s\n", colorer.Green("echoext"), colorer.Blue(c.healthcheckFullPath()))
 
-	if !c.MetricsConfig.Disabled {
-		colorer.Printf("[%s] metrics: %s\n", colorer.Green("echoext"), colorer.Blue(fmt.Sprintf("http://%s:%d%s", "0.0.0.0", c.MetricsConfig.escapePort(), c.MetricsConfig.escapePath())))
+	if c.TLSConfig.enabled() {
+		mode := "enabled"
+		if c.TLSConfig.ClientCAFile != "" {
+			mode = "mutual"
+		}
+
+		colorer.Printf("[%s] tls: %s\n", colorer.Green("echoext"), colorer.Blue(mode))
+	}
+
+	if c.ProtocolConfig.H2C {
+		colorer.Printf("[%s] h2c: %s\n", colorer.Green("echoext"), colorer.Blue("enabled"))
 	}
 
-	if env != "production" {
+	if profile.Swagger {
 		sp := c.swaggerPath()
-		colorer.Printf("[%s] swagger docs: %s\n", colorer.Green("echoext"), colorer.Blue("http://"+c.escapeHost()+sp+"/index.html"))
-		swaggerCreds := os.Getenv("SWAGGER_CREDENTIALS")
+		colorer.Printf("[%s] swagger docs: %s\n", colorer.Green("echoext"), colorer.Blue(c.TLSConfig.scheme()+"://"+c.escapeHost()+sp+"/index.html"))
 
-		swaggerUser := ""
-		swaggerPass := ""
+		if c.SwaggerConfig.Credentials.IsZero() {
+			creds, err := DefaultSecretStore.Resolve(os.Getenv("SWAGGER_CREDENTIALS"))
+			if err != nil {
+				cfgErr = errors.Join(cfgErr, fmt.Errorf("swagger: %w", err))
+			}
 
-		parts := strings.Split(swaggerCreds, ":")
-		if len(parts) == 2 {
-			swaggerUser = parts[0]
-			swaggerPass = parts[1]
+			c.SwaggerConfig.Credentials = creds
 		}
 
 		swaggerAuth := emiddleware.BasicAuthWithConfig(emiddleware.BasicAuthConfig{
 			Skipper: nil,
 			Validator: func(u string, p string, ctx echo.Context) (bool, error) {
-				return u == swaggerUser && p == swaggerPass, nil
+				swaggerUser, swaggerPass := c.SwaggerConfig.credentials()
+				// Both are compared so the time taken does not reveal which
+				// one was wrong.
+				userOK, passOK := secureCompare(u, swaggerUser), secureCompare(p, swaggerPass)
+				return userOK && passOK, nil
 			},
 			Realm: "",
 		})
 
-		s.GET(sp+"/*", echoSwagger.EchoWrapHandler(), swaggerAuth)
+		var swaggerOpts []func(*echoSwagger.Config)
+		if name := registerAnnotatedDoc(routes); name != "" {
+			swaggerOpts = append(swaggerOpts, echoSwagger.InstanceName(name))
+		}
+
+		s.GET(sp+"/*", echoSwagger.EchoWrapHandler(swaggerOpts...), swaggerAuth)
+	}
+
+	servers := make([]extServer, 0, len(c.Servers))
+	for _, n := range c.Servers {
+		servers = append(servers, newNamedServer(c, n, colorer, dynamic))
+	}
+
+	for _, w := range c.productionWarnings() {
+		colorer.Printf("[%s] %s: %s\n", colorer.Green("echoext"), colorer.Yellow("warning"), w)
 	}
 
 	colorer.Println()
@@ -125,9 +159,100 @@ func New(cl ...ServerConfig) Server {
 		Echo:    s,
 		config:  c,
 		colorer: colorer,
-		appEnv:  env,
-		root:    &Group{root},
+		env:     c.Environment,
+		root:    &Group{Group: root, routes: routes},
+		routes:  routes,
+		name:    "main",
+		servers: servers,
+		dynamic: dynamic,
+		err:     cfgErr,
+	}
+}
+
+// newEngine builds an echo instance with the default middleware stack and
+// the healthcheck, returning it with its root group. name is the server's
+// name, which the global limiters are named after.
+func newEngine(c ServerConfig, name string, cors bool, dynamic *dynamicConfig) (*echo.Echo, *echo.Group) {
+	s := echo.New()
+	s.HideBanner = true
+
+	// An invalid proxy config is reported by Start; until then no proxy is
+	// trusted.
+	proxy, err := c.ProxyConfig.resolver()
+	if err != nil {
+		proxy = &proxyResolver{header: ProxyXForwardedFor}
+	}
+
+	proxy.apply(s)
+	c.TimeoutConfig.apply(s.Server)
+	c.ProtocolConfig.apply(s.Server)
+
+	profile := c.profile()
+	s.HTTPErrorHandler = errorHandler(s, profile.ErrorDetail)
+	dynamic.apply(func(st *dynamicState) { s.Logger.SetLevel(st.level) })
+
+	var logHeader string
+	if profile.LogFormat == TextLogFormat {
+		logHeader = "${time_rfc3339} ${level} ${short_file}:${line}"
+		s.Logger.SetHeader(logHeader)
+	}
+
+	s.Validator = newValidator()
+	s.Pre(setEnvironment(c.environment()), dynamic.middleware)
+
+	s.Use(CustomLogger(c))
+	s.Use(CustomRecovery)
+
+	if cors {
+		s.Use(dynamicCORS(c, dynamic))
+	}
+
+	if !c.SecurityConfig.Disabled {
+		s.Use(adaptMiddleware(securityHeaders(c.SecurityConfig, c.swaggerPath())))
 	}
+
+	if !c.MetricsConfig.Disabled {
+		s.Use(metricsMiddleware)
+	}
+
+	if c.ConcurrencyLimitConfig.enabled() {
+		cl := c.ConcurrencyLimitConfig
+		cl.Name = serverLimiterName(cl.Name, name)
+		s.Use(skipBuiltin(c, adaptMiddleware(ConcurrencyLimit(cl))))
+	}
+
+	// With reloading, a limit may be set later, so the middleware is always
+	// installed and reads the current rule.
+	if c.RateLimitConfig.enabled() || c.ReloadConfig.enabled() {
+		rl := c.RateLimitConfig
+		rl.Prefix = serverLimiterName(rl.Prefix, name)
+		s.Use(skipBuiltin(c, adaptMiddleware(rateLimit(rl, dynamic.rateLimitRule))))
+	}
+
+	// Compression runs before Body so body limits apply to decompressed
+	// request bodies.
+	if !c.CompressionConfig.Disabled {
+		s.Use(skipBuiltin(c, adaptMiddleware(Compression(c.CompressionConfig))))
+	}
+
+	if c.BodyConfig.enabled() {
+		s.Use(skipBuiltin(c, adaptMiddleware(Body(c.BodyConfig))))
+	}
+
+	// Debug rules run after Body so logged request bodies are decompressed
+	// and within limits, and outside Timeout so timeouts are logged too.
+	s.Use(skipBuiltin(c, debugLogging(c, &dynamic.rules, logHeader)))
+
+	if c.TimeoutConfig.Request > 0 {
+		s.Use(skipBuiltin(c, adaptMiddleware(Timeout(c.TimeoutConfig.Request))))
+	}
+
+	root := s.Group(c.PathPrefix)
+	root.GET(c.escapeHealthcheckSuffix(), func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, echo.Map{"status": "ok"})
+	})
+
+	return s, root
 }
 
 type setupfn func(*Group)
@@ -152,7 +277,12 @@ func escapePath(path string) string {
 func (s extServer) Group(prefix string, mount setupfn, middlewares ...MiddlewareFunc) *Group {
 	p := escapePath(prefix)
 
-	s.colorer.Printf("[%s] group prefix: %s\n", s.colorer.Green("echoext"), s.colorer.Blue(s.config.PathPrefix+p))
+	label := "group prefix"
+	if s.name != "main" {
+		label = s.name + " group prefix"
+	}
+
+	s.colorer.Printf("[%s] %s: %s\n", s.colorer.Green("echoext"), label, s.colorer.Blue(s.config.PathPrefix+p))
 
 	g := s.root.NewGroup(p, middlewares...)
 
@@ -161,51 +291,246 @@ func (s extServer) Group(prefix string, mount setupfn, middlewares ...Middleware
 	return g
 }
 
-// Start boots the main HTTP server and, unless disabled, the dedicated
-// Prometheus metrics server. It blocks until either server fails or an
-// interrupt/terminate signal is received, at which point both servers are
-// gracefully shut down within shutdownTimeout.
+// Start returns the configuration errors found by New without serving.
+// Otherwise it boots the main HTTP server, the additional named servers and, unless
+// disabled, the dedicated Prometheus metrics server. It blocks until any
+// server fails or an interrupt/terminate signal is received, at which point
+// all servers are gracefully shut down within shutdownTimeout. With
+// RestartConfig enabled, the restart signal hands the listeners to a new
+// process first. With ReloadConfig enabled, the reload signal and changes to
+// its file reload DynamicConfig. With LogConfig.EnableAdmin, its toggle
+// signal switches debug logging on and off. DefaultSecretStore is refreshed until Start returns.
 func (s extServer) Start() error {
-	// Buffered for both servers so a failing goroutine never blocks on send.
-	errCh := make(chan error, 2)
+	if s.err != nil {
+		return fmt.Errorf("echoext: %w", s.err)
+	}
+
+	// Secrets follow their rotations while the servers run.
+	defer DefaultSecretStore.StartRefresh()()
+
+	all := append([]extServer{s}, s.servers...)
+
+	for _, srv := range all {
+		label := "route"
+		if srv.name != "main" {
+			label = srv.name + " route"
+		}
+
+		for _, r := range srv.routes.list() {
+			s.colorer.Printf("[%s] %s: %s\n", s.colorer.Green("echoext"), label, s.colorer.Blue(r))
+		}
+	}
+
+	inherited, err := inherit()
+	if err != nil {
+		return err
+	}
+
+	listeners := make([]namedListener, 0, len(all)+1)
+	closeAll := func() {
+		for _, l := range listeners {
+			l.ln.Close()
+		}
+	}
+
+	for _, srv := range all {
+		ln, err := srv.listen(inherited.listener(srv.name))
+		if err != nil {
+			closeAll()
+			return err
+		}
+
+		listeners = append(listeners, namedListener{name: srv.name, ln: ln})
+	}
+
+	// Buffered for every server so a failing goroutine never blocks on send.
+	errCh := make(chan error, len(all)+1)
 
 	var metricsSrv *http.Server
 	if !s.config.MetricsConfig.Disabled {
-		metricsSrv = newMetricsServer(s.config)
+		srv, metricsLn, err := s.listenMetrics(inherited.listener("metrics"))
+		if err != nil {
+			closeAll()
+			return err
+		}
+
+		metricsSrv = srv
+		listeners = append(listeners, namedListener{name: "metrics", ln: metricsLn})
 		go func() {
-			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := s.serveMetrics(srv, metricsLn); err != nil && err != http.ErrServerClosed {
 				errCh <- err
 			}
 		}()
 	}
 
-	go func() {
-		if err := s.Echo.Start(s.config.escapeHost()); err != nil && err != http.ErrServerClosed {
-			errCh <- err
-		}
-	}()
+	for _, srv := range all {
+		go func() {
+			if err := srv.Echo.StartServer(srv.Echo.Server); err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		}()
+	}
+
+	inherited.signalReady()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	select {
-	case err := <-errCh:
-		// One of the servers failed to start; tear down the other.
-		s.shutdown(metricsSrv)
-		return err
-	case <-quit:
-		return s.shutdown(metricsSrv)
+	restart := make(chan os.Signal, 1)
+	if s.config.RestartConfig.Enabled {
+		signal.Notify(restart, s.config.RestartConfig.signal())
+	}
+
+	reload := make(chan os.Signal, 1)
+	if s.config.ReloadConfig.enabled() {
+		signal.Notify(reload, s.config.ReloadConfig.signal())
+
+		if s.config.ReloadConfig.pollInterval() > 0 {
+			stop := make(chan struct{})
+			defer close(stop)
+
+			go s.watchReloadFile(stop)
+		}
+	}
+
+	toggle := make(chan os.Signal, 1)
+	if s.config.LogConfig.EnableAdmin {
+		signal.Notify(toggle, s.config.LogConfig.toggleSignal())
+	}
+
+	for {
+		select {
+		case err := <-errCh:
+			// One of the servers failed to start; tear down the others.
+			s.shutdown(metricsSrv)
+			return err
+		case <-quit:
+			return s.shutdown(metricsSrv)
+		case <-reload:
+			_, _ = s.reload("signal")
+		case <-toggle:
+			s.toggleDebug()
+		case <-restart:
+			pid, err := handoff(listeners, s.config.RestartConfig.readyTimeout())
+			if err != nil {
+				s.Echo.Logger.Errorf("%v", err)
+				continue
+			}
+
+			s.colorer.Printf("[%s] restart: handed off to pid %s\n", s.colorer.Green("echoext"), s.colorer.Blue(pid))
+
+			// The new process serves the socket files now.
+			for _, l := range listeners {
+				if ul, ok := l.ln.(*net.UnixListener); ok {
+					ul.SetUnlinkOnClose(false)
+				}
+			}
+
+			return s.shutdown(metricsSrv)
+		}
+	}
+}
+
+// listen prepares the server's TLS config and listener, using the inherited
+// listener when one is given, and returns the raw listener.
+func (s extServer) listen(inherited net.Listener) (net.Listener, error) {
+	if s.config.TLSConfig.enabled() {
+		tlsConfig, err := s.config.TLSConfig.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.NextProtos = s.config.ProtocolConfig.nextProtos()
+		s.Echo.Server.TLSConfig = tlsConfig
+	}
+
+	ln := inherited
+	if ln == nil {
+		var err error
+		if ln, err = s.config.ListenerConfig.listen(s.config.escapeHost()); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Echo.Server.TLSConfig != nil {
+		s.Echo.TLSListener = tls.NewListener(ln, s.Echo.Server.TLSConfig)
+	} else {
+		s.Echo.Listener = ln
+	}
+
+	return ln, nil
+}
+
+// listenMetrics builds the metrics server and binds its listener up front so
+// the banner reports the address actually bound rather than the configured one.
+// An inherited listener is used as is.
+func (s extServer) listenMetrics(inherited net.Listener) (*http.Server, net.Listener, error) {
+	admin := map[string]http.HandlerFunc{}
+	if s.config.ReloadConfig.enabled() {
+		if s.config.MetricsConfig.protected() {
+			admin["/admin/reload"] = s.reloadHandler
+		} else {
+			s.colorer.Printf("[%s] %s: %s\n", s.colorer.Green("echoext"), s.colorer.Yellow("warning"), "reload endpoint not mounted; set MetricsConfig Username, BearerToken or AllowedCIDRs")
+		}
+	}
+
+	if s.config.LogConfig.EnableAdmin {
+		if s.config.MetricsConfig.protected() {
+			admin["/admin/log-level"] = s.logLevelHandler
+			admin["/admin/debug-rules"] = s.debugRulesHandler
+		} else {
+			s.colorer.Printf("[%s] %s: %s\n", s.colorer.Green("echoext"), s.colorer.Yellow("warning"), "log admin endpoints not mounted; set MetricsConfig Username, BearerToken or AllowedCIDRs")
+		}
 	}
+
+	srv, err := newMetricsServer(s.config, admin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ln := inherited
+	if ln == nil {
+		if ln, err = net.Listen("tcp", srv.Addr); err != nil {
+			return nil, nil, fmt.Errorf("metrics listen: %w", err)
+		}
+	}
+
+	mc := s.config.MetricsConfig
+	s.colorer.Printf("[%s] metrics: %s\n", s.colorer.Green("echoext"), s.colorer.Blue(fmt.Sprintf("%s://%s%s", mc.scheme(), ln.Addr(), mc.escapePath())))
+
+	if s.config.servePprof() {
+		s.colorer.Printf("[%s] pprof: %s\n", s.colorer.Green("echoext"), s.colorer.Blue(fmt.Sprintf("%s://%s/debug/pprof/", mc.scheme(), ln.Addr())))
+	} else if s.config.profile().Pprof {
+		s.colorer.Printf("[%s] %s: %s\n", s.colorer.Green("echoext"), s.colorer.Yellow("warning"), "pprof not mounted; bind the metrics server to loopback or set MetricsConfig Username, BearerToken or AllowedCIDRs")
+	}
+
+	return srv, ln, nil
+}
+
+// serveMetrics serves the metrics server on ln, over TLS when configured.
+func (s extServer) serveMetrics(srv *http.Server, ln net.Listener) error {
+	mc := s.config.MetricsConfig
+	if mc.tlsEnabled() {
+		return srv.ServeTLS(ln, mc.TLSCertFile, mc.TLSKeyFile)
+	}
+
+	return srv.Serve(ln)
 }
 
-// shutdown gracefully stops the main server and, when present, the metrics
-// server, sharing a single timeout-bounded context.
+// shutdown gracefully stops the main server, the named servers and, when
+// present, the metrics server, sharing a single timeout-bounded context.
 func (s extServer) shutdown(metricsSrv *http.Server) error {
 	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), shutdownTimeout)
 	defer cancel()
 
 	err := s.Echo.Shutdown(ctx)
 
+	for _, srv := range s.servers {
+		if sErr := srv.Echo.Shutdown(ctx); sErr != nil && err == nil {
+			err = sErr
+		}
+	}
+
 	if metricsSrv != nil {
 		if mErr := metricsSrv.Shutdown(ctx); mErr != nil && err == nil {
 			err = mErr
@@ -215,6 +540,70 @@ func (s extServer) shutdown(metricsSrv *http.Server) error {
 	return err
 }
 
+// errorHandler renders errors with echo's default handler. Body size limit
+// errors from reads outside Bind become 413. With detail, the internal error
+// message is added to string messages as echo's debug mode does, without
+// debug mode's pretty-printed JSON responses, which would also change the
+// bytes JSONETag hashes.
+func errorHandler(e *echo.Echo, detail bool) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		err = bodyError(err)
+		if !detail {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+
+		he, ok := err.(*echo.HTTPError)
+		if !ok {
+			he = echo.NewHTTPError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		} else if internal, ok := he.Internal.(*echo.HTTPError); ok {
+			he = internal
+		}
+
+		if m, ok := he.Message.(string); ok {
+			e.DefaultHTTPErrorHandler(&echo.HTTPError{Code: he.Code, Message: echo.Map{"message": m, "error": err.Error()}}, c)
+			return
+		}
+
+		e.DefaultHTTPErrorHandler(err, c)
+	}
+}
+
+// serverLimiterName names a global limiter of server: the server name when
+// unnamed, and the configured name suffixed with the server name on named
+// servers, so their copies of the limiter never share metrics or keys.
+func serverLimiterName(configured, server string) string {
+	switch {
+	case configured == "":
+		return server
+	case server == "main":
+		return configured
+	default:
+		return configured + "_" + server
+	}
+}
+
+// Routes returns the routes registered through groups, with the
+// authorization requirements enforced on each.
+func (s extServer) Routes() []RouteInfo {
+	return s.routes.list()
+}
+
 func (s extServer) Engine() *echo.Echo {
 	return s.Echo
 }
+
+// Environment returns the environment the server runs in.
+func (s extServer) Environment() Environment {
+	return s.env
+}
+
+func (s extServer) Named(name string) Router {
+	for _, srv := range s.servers {
+		if srv.name == name {
+			return srv
+		}
+	}
+
+	panic("echoext: unknown server " + name)
+}
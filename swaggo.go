@@ -1,9 +1,31 @@
 package echoext
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/swaggo/swag"
+)
 
 type SwaggerConfig struct {
 	Prefix string
+	// Credentials protect the docs with basic auth as "username:password".
+	// Defaults to the SWAGGER_CREDENTIALS environment variable, which may
+	// also hold a secret reference such as file://swagger.
+	Credentials Secret
+}
+
+// credentials returns the configured basic auth pair, read at request time
+// so rotated secrets apply immediately.
+func (c *SwaggerConfig) credentials() (string, string) {
+	user, pass, ok := strings.Cut(c.Credentials.Value(), ":")
+	if !ok || strings.Contains(pass, ":") {
+		return "", ""
+	}
+
+	return user, pass
 }
 
 func (c *SwaggerConfig) escapePrefix() string {
@@ -26,3 +48,90 @@ func (c *SwaggerConfig) escapePrefix() string {
 
 	return strings.ToLower(prefix)
 }
+
+// swagInstances numbers the annotated swag instances registered by servers,
+// since swag panics when a name is registered twice.
+var swagInstances atomic.Int64
+
+// annotatedDoc serves the generated Swagger document with the authorization
+// requirements of each route added to its operations, so the docs always
+// match what the middleware enforces.
+type annotatedDoc struct {
+	base   swag.Swagger
+	routes *routeTable
+}
+
+// registerAnnotatedDoc registers an annotatedDoc wrapping the default swag
+// instance and returns its name. It returns "" when no docs are registered.
+func registerAnnotatedDoc(routes *routeTable) string {
+	base := swag.GetSwagger(swag.Name)
+	if base == nil {
+		return ""
+	}
+
+	name := fmt.Sprintf("echoext-%d", swagInstances.Add(1))
+	swag.Register(name, &annotatedDoc{base: base, routes: routes})
+
+	return name
+}
+
+// ReadDoc implements swag.Swagger. Each operation with requirements gets an
+// "x-authorization" extension and a line appended to its description. The
+// original document is returned untouched if it cannot be parsed.
+func (d *annotatedDoc) ReadDoc() string {
+	doc := d.base.ReadDoc()
+
+	var spec map[string]any
+	if err := json.Unmarshal([]byte(doc), &spec); err != nil {
+		return doc
+	}
+
+	paths, _ := spec["paths"].(map[string]any)
+	basePath, _ := spec["basePath"].(string)
+	basePath = strings.TrimSuffix(basePath, "/")
+
+	for _, r := range d.routes.list() {
+		if len(r.Authorization) == 0 {
+			continue
+		}
+
+		item, _ := paths[swaggerPath(strings.TrimPrefix(r.Path, basePath))].(map[string]any)
+		op, _ := item[strings.ToLower(r.Method)].(map[string]any)
+		if op == nil {
+			continue
+		}
+
+		reqs := make([]string, len(r.Authorization))
+		for i, req := range r.Authorization {
+			reqs[i] = req.String()
+		}
+
+		op["x-authorization"] = reqs
+
+		desc, _ := op["description"].(string)
+		if desc != "" {
+			desc += "\n\n"
+		}
+		op["description"] = desc + "**Authorization:** " + strings.Join(reqs, "; ")
+	}
+
+	out, err := json.Marshal(spec)
+	if err != nil {
+		return doc
+	}
+
+	return string(out)
+}
+
+// swaggerPath converts an echo route path ("/users/:id") to the Swagger
+// template form ("/users/{id}").
+func swaggerPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if name, ok := strings.CutPrefix(s, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
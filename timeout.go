@@ -0,0 +1,108 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// timeoutParentKey stores the request context as it was before any Timeout
+// middleware ran, so nested timeouts replace outer ones instead of only being
+// able to shorten them.
+const timeoutParentKey = "echoext.timeout.parent"
+
+// timeoutWriteGrace is the time left after a request deadline to write the
+// timeout response.
+const timeoutWriteGrace = 5 * time.Second
+
+// errRequestTimeout is returned when a handler outlives its deadline.
+var errRequestTimeout = newHTTPError(http.StatusServiceUnavailable, "request timed out")
+
+// TimeoutConfig configures request deadlines and the underlying http.Server
+// timeouts. Server timeouts default to safe non-zero values; set a negative
+// duration to disable one.
+type TimeoutConfig struct {
+	// Request bounds every handler. Zero disables the global deadline;
+	// groups and routes can still set their own with Timeout.
+	Request time.Duration
+	// ReadHeader is http.Server.ReadHeaderTimeout. Defaults to 10s.
+	ReadHeader time.Duration
+	// Read is http.Server.ReadTimeout. Defaults to 30s.
+	Read time.Duration
+	// Write is http.Server.WriteTimeout. Disabled by default so streaming
+	// responses and downloads are not cut off; Timeout extends it for
+	// requests with a longer deadline.
+	Write time.Duration
+	// Idle is http.Server.IdleTimeout. Defaults to 120s.
+	Idle time.Duration
+}
+
+func escapeTimeout(d, def time.Duration) time.Duration {
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return def
+	default:
+		return d
+	}
+}
+
+// apply sets the configured timeouts on srv.
+func (c *TimeoutConfig) apply(srv *http.Server) {
+	srv.ReadHeaderTimeout = escapeTimeout(c.ReadHeader, 10*time.Second)
+	srv.ReadTimeout = escapeTimeout(c.Read, 30*time.Second)
+	srv.WriteTimeout = escapeTimeout(c.Write, 0)
+	srv.IdleTimeout = escapeTimeout(c.Idle, 120*time.Second)
+}
+
+// Timeout returns a middleware that sets a deadline of d on the request
+// context. Handlers are expected to honour Request().Context(); when the
+// deadline passes before a response is written the request fails with 503 and
+// is counted in http_request_timeouts_total. The innermost Timeout wins, so a
+// route can both shorten and extend its group's or the server's deadline,
+// and the server's WriteTimeout is extended to match.
+func Timeout(d time.Duration) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			if d <= 0 {
+				return next(c)
+			}
+
+			parent, ok := c.Get(timeoutParentKey).(stdcontext.Context)
+			if !ok {
+				parent = c.Request().Context()
+				c.Set(timeoutParentKey, parent)
+			}
+
+			ctx, cancel := stdcontext.WithTimeout(parent, d)
+			defer cancel()
+
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			// A shorter server WriteTimeout would cut the response off
+			// before the deadline, so push the write deadline past it.
+			if srv, ok := parent.Value(http.ServerContextKey).(*http.Server); ok && srv.WriteTimeout > 0 && d > srv.WriteTimeout {
+				_ = http.NewResponseController(c.Response().Writer).SetWriteDeadline(time.Now().Add(d + timeoutWriteGrace))
+			}
+
+			err := next(c)
+
+			// Inner Timeout middlewares may have swapped the context, so
+			// check whichever deadline actually applied to the handler.
+			if c.Response().Committed || !errors.Is(c.Request().Context().Err(), stdcontext.DeadlineExceeded) {
+				return err
+			}
+
+			if err == errRequestTimeout {
+				// Already reported by an inner Timeout.
+				return err
+			}
+
+			httpRequestTimeouts.WithLabelValues(c.Request().Method, routeLabel(c.Path())).Inc()
+
+			return errRequestTimeout
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package echoext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sleepHandler answers after d unless the request deadline passes first.
+func sleepHandler(d time.Duration) HandlerFunc {
+	return func(c Context) error {
+		select {
+		case <-time.After(d):
+			return c.String(http.StatusOK, "done")
+		case <-c.Request().Context().Done():
+			return c.Request().Context().Err()
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   time.Duration
+		group    []MiddlewareFunc
+		route    []MiddlewareFunc
+		handler  HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{name: "within the deadline", route: []MiddlewareFunc{Timeout(time.Second)}, handler: sleepHandler(0), wantCode: http.StatusOK, wantBody: "done"},
+		{name: "deadline passes", route: []MiddlewareFunc{Timeout(10 * time.Millisecond)}, handler: sleepHandler(time.Second), wantCode: http.StatusServiceUnavailable, wantBody: "request timed out"},
+		{name: "server deadline", server: 10 * time.Millisecond, handler: sleepHandler(time.Second), wantCode: http.StatusServiceUnavailable, wantBody: "request timed out"},
+		{
+			name:     "handler ignores the error",
+			route:    []MiddlewareFunc{Timeout(10 * time.Millisecond)},
+			handler:  func(c Context) error { <-c.Request().Context().Done(); return nil },
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "request timed out",
+		},
+		{
+			name:     "route extends the group deadline",
+			group:    []MiddlewareFunc{Timeout(10 * time.Millisecond)},
+			route:    []MiddlewareFunc{Timeout(time.Second)},
+			handler:  sleepHandler(50 * time.Millisecond),
+			wantCode: http.StatusOK,
+			wantBody: "done",
+		},
+		{
+			name:     "route shortens the group deadline",
+			group:    []MiddlewareFunc{Timeout(time.Second)},
+			route:    []MiddlewareFunc{Timeout(10 * time.Millisecond)},
+			handler:  sleepHandler(500 * time.Millisecond),
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "request timed out",
+		},
+		{
+			name:  "response committed before the deadline",
+			route: []MiddlewareFunc{Timeout(10 * time.Millisecond)},
+			handler: func(c Context) error {
+				if err := c.String(http.StatusOK, "partial"); err != nil {
+					return err
+				}
+
+				<-c.Request().Context().Done()
+
+				return c.Request().Context().Err()
+			},
+			wantCode: http.StatusOK,
+			wantBody: "partial",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(ServerConfig{
+				Environment:   Production,
+				TimeoutConfig: TimeoutConfig{Request: tt.server},
+				MetricsConfig: MetricsConfig{Disabled: true},
+			})
+
+			srv.Group("slow", func(g *Group) {
+				g.GET("", tt.handler, tt.route...)
+			}, tt.group...)
+
+			rec := httptest.NewRecorder()
+			srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+			if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("response = %d %q, want %d containing %q", rec.Code, rec.Body, tt.wantCode, tt.wantBody)
+			}
+
+			if tt.wantCode == http.StatusOK && strings.Contains(rec.Body.String(), "timed out") {
+				t.Fatalf("committed response has the timeout error appended: %q", rec.Body)
+			}
+		})
+	}
+}
+
+func TestGroupWithTimeout(t *testing.T) {
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("reports", func(g *Group) {
+		g.WithTimeout(10*time.Millisecond).GET("/summary", sleepHandler(time.Second))
+		g.WithTimeout(10*time.Millisecond).GET("/export", sleepHandler(50*time.Millisecond), Timeout(time.Second))
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/reports/summary", want: http.StatusServiceUnavailable},
+		{path: "/reports/export", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package echoext
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsReloadCheckInterval bounds how often certificate files are checked for
+// changes.
+const tlsReloadCheckInterval = 5 * time.Second
+
+// TLSConfig serves the main server over HTTPS. Certificate, key and client
+// CA files are reloaded when they change on disk, so rotated certificates
+// (for example by cert-manager) are picked up without a restart.
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM server certificate chain and key.
+	// Both are required to enable TLS.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM CAs client certificates are verified
+	// against. Setting it enables mutual TLS.
+	ClientCAFile string
+	// OptionalClientCert accepts clients without a certificate when mutual
+	// TLS is enabled. Certificates that are presented must still verify.
+	OptionalClientCert bool
+	// MinVersion defaults to TLS 1.2.
+	MinVersion uint16
+}
+
+func (c *TLSConfig) enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// validate rejects a certificate without a key or the reverse, which would
+// otherwise silently serve plain HTTP.
+func (c *TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("tls: CertFile and KeyFile must be set together")
+	}
+
+	return nil
+}
+
+func (c *TLSConfig) scheme() string {
+	if c.enabled() {
+		return "https"
+	}
+
+	return "http"
+}
+
+func (c *TLSConfig) clientAuth() tls.ClientAuthType {
+	switch {
+	case c.ClientCAFile == "":
+		return tls.NoClientCert
+	case c.OptionalClientCert:
+		return tls.VerifyClientCertIfGiven
+	default:
+		return tls.RequireAndVerifyClientCert
+	}
+}
+
+// Load reads the files and returns the *tls.Config Start serves with. Each
+// handshake uses the latest files and the config's NextProtos. It is also
+// useful to serve a handler with httptest.NewUnstartedServer in tests.
+func (c TLSConfig) Load() (*tls.Config, error) {
+	if !c.enabled() {
+		return nil, fmt.Errorf("tls: CertFile and KeyFile are required")
+	}
+
+	if c.MinVersion == 0 {
+		c.MinVersion = tls.VersionTLS12
+	}
+
+	r := &certReloader{certFile: c.CertFile, keyFile: c.KeyFile, caFile: c.ClientCAFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion: c.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	clientAuth := c.clientAuth()
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, pool := r.current()
+
+		return &tls.Config{
+			MinVersion:   base.MinVersion,
+			NextProtos:   base.NextProtos,
+			Certificates: []tls.Certificate{*cert},
+			ClientCAs:    pool,
+			ClientAuth:   clientAuth,
+		}, nil
+	}
+
+	return base, nil
+}
+
+// certReloader keeps the server certificate and client CA pool in sync with
+// their files.
+type certReloader struct {
+	certFile, keyFile, caFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+// current returns the loaded certificate and pool, reloading them when the
+// files changed. A failed reload keeps the previous ones, so a half-written
+// rotation never takes the server down.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) > tlsReloadCheckInterval {
+		r.checkedAt = time.Now()
+		if mt, err := r.stat(); err == nil && mt != r.modTimes {
+			_ = r.reload()
+		}
+	}
+
+	return r.cert, r.pool
+}
+
+// stat returns the modification times of the files.
+func (r *certReloader) stat() ([3]time.Time, error) {
+	var mt [3]time.Time
+	for i, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return mt, fmt.Errorf("tls: %w", err)
+		}
+
+		mt[i] = fi.ModTime()
+	}
+
+	return mt, nil
+}
+
+// reload reads the files. Callers must hold r.mu or own r exclusively.
+func (r *certReloader) reload() error {
+	mt, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificates found in %s", r.caFile)
+		}
+	}
+
+	r.cert, r.pool, r.modTimes = &cert, pool, mt
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package echoext
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate for cn and its key to
+// certFile and keyFile, with modification time mtime.
+func writeSelfSigned(t *testing.T, certFile, keyFile, cn string, mtime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	}
+
+	for path, block := range files {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// certCN returns the common name of the certificate r serves.
+func certCN(t *testing.T, r *certReloader) string {
+	t.Helper()
+
+	cert, _ := r.current()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	tests := []struct {
+		name string
+		// rotate changes the files after the first load.
+		rotate func(t *testing.T, certFile, keyFile string)
+		// expire lets the next handshake check the files.
+		expire bool
+		wantCN string
+	}{
+		{
+			name: "rotated files picked up",
+			rotate: func(t *testing.T, certFile, keyFile string) {
+				writeSelfSigned(t, certFile, keyFile, "second", time.Now().Add(time.Minute))
+			},
+			expire: true,
+			wantCN: "second",
+		},
+		{
+			name: "rotation within check interval waits",
+			rotate: func(t *testing.T, certFile, keyFile string) {
+				writeSelfSigned(t, certFile, keyFile, "second", time.Now().Add(time.Minute))
+			},
+			wantCN: "first",
+		},
+		{
+			name: "half-written rotation keeps previous",
+			rotate: func(t *testing.T, certFile, _ string) {
+				if err := os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				mtime := time.Now().Add(time.Minute)
+				if err := os.Chtimes(certFile, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expire: true,
+			wantCN: "first",
+		},
+		{
+			name: "removed files keep previous",
+			rotate: func(t *testing.T, certFile, _ string) {
+				if err := os.Remove(certFile); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expire: true,
+			wantCN: "first",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+			writeSelfSigned(t, certFile, keyFile, "first", time.Now())
+
+			r := &certReloader{certFile: certFile, keyFile: keyFile}
+			if err := r.reload(); err != nil {
+				t.Fatal(err)
+			}
+
+			r.checkedAt = time.Now()
+			tt.rotate(t, certFile, keyFile)
+
+			if tt.expire {
+				r.checkedAt = time.Time{}
+			}
+
+			if got := certCN(t, r); got != tt.wantCN {
+				t.Fatalf("serving %q, want %q", got, tt.wantCN)
+			}
+		})
+	}
+}
+
+func TestTLSConfigLoadServes(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSigned(t, certFile, keyFile, "served", time.Now())
+
+	cfg, err := TLSConfig{CertFile: certFile, KeyFile: keyFile}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != "served" {
+		t.Fatalf("served certificate %q, want %q", cn, "served")
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TLSConfig
+		wantErr bool
+	}{
+		{name: "disabled", cfg: TLSConfig{}},
+		{name: "both set", cfg: TLSConfig{CertFile: "tls.crt", KeyFile: "tls.key"}},
+		{name: "cert only", cfg: TLSConfig{CertFile: "tls.crt"}, wantErr: true},
+		{name: "key only", cfg: TLSConfig{KeyFile: "tls.key"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStartRejectsHalfConfiguredTLS(t *testing.T) {
+	srv := New(ServerConfig{
+		Environment:   Production,
+		TLSConfig:     TLSConfig{CertFile: "tls.crt"},
+		MetricsConfig: MetricsConfig{Disabled: true},
+	})
+
+	if err := srv.Start(); err == nil {
+		t.Fatal("Start served with only CertFile set")
+	}
+}
@@ -13,3 +13,11 @@ func (v *Validator) Validate(i interface{}) error {
 
 	return nil
 }
+
+func newValidator() *Validator {
+	return &Validator{
+		v: validator.New(
+			validator.WithRequiredStructEnabled(),
+		),
+	}
+}
@@ -0,0 +1,260 @@
+package echoext
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RawBodyKey is the context key holding the raw request body read by
+// VerifyWebhook.
+const RawBodyKey = "raw_body"
+
+var errInvalidSignature = newHTTPError(http.StatusUnauthorized, "invalid webhook signature")
+
+// WebhookAlgorithm selects the HMAC hash used to sign webhooks.
+type WebhookAlgorithm string
+
+const (
+	WebhookSHA256 WebhookAlgorithm = "sha256"
+	WebhookSHA512 WebhookAlgorithm = "sha512"
+	WebhookSHA1   WebhookAlgorithm = "sha1"
+)
+
+// webhookHashes maps each WebhookAlgorithm to its hash.
+var webhookHashes = map[WebhookAlgorithm]func() hash.Hash{
+	WebhookSHA256: sha256.New,
+	WebhookSHA512: sha512.New,
+	WebhookSHA1:   sha1.New,
+}
+
+// WebhookFormat selects how the signature header is laid out.
+type WebhookFormat string
+
+const (
+	// WebhookPlain headers carry a single signature, optionally behind
+	// Prefix (GitHub-style "sha256=<hex>").
+	WebhookPlain WebhookFormat = "plain"
+	// WebhookStripe headers carry "t=<unix>,v1=<hex>[,v1=<hex>...]". The
+	// signed payload is "<t>.<body>".
+	WebhookStripe WebhookFormat = "stripe"
+)
+
+// WebhookConfig configures inbound webhook verification.
+type WebhookConfig struct {
+	// Header carries the signature.
+	Header string
+	// Secrets are the active signing secrets. A signature matching any of
+	// them is accepted, which allows rotation without downtime. Their values
+	// are read on each request, so rotated references apply at once. At
+	// least one is required.
+	Secrets []Secret
+	// Algorithm defaults to WebhookSHA256.
+	Algorithm WebhookAlgorithm
+	// Format defaults to WebhookPlain.
+	Format WebhookFormat
+	// Prefix is stripped from plain signatures, such as "sha256=".
+	Prefix string
+	// Base64 decodes plain signatures as standard base64 instead of hex.
+	Base64 bool
+	// TimestampHeader carries the signing time as Unix seconds for plain
+	// signatures. When set, the signed payload is "<timestamp>.<body>".
+	TimestampHeader string
+	// Tolerance is the maximum age (or clock skew) of a timestamped
+	// signature. Defaults to five minutes.
+	Tolerance time.Duration
+	// MaxBodyBytes bounds the body read for verification. Defaults to 1 MiB.
+	MaxBodyBytes int64
+}
+
+// StripeWebhook returns a WebhookConfig for Stripe-style signatures in the
+// Stripe-Signature header.
+func StripeWebhook(secrets ...Secret) WebhookConfig {
+	return WebhookConfig{
+		Header:  "Stripe-Signature",
+		Secrets: secrets,
+		Format:  WebhookStripe,
+	}
+}
+
+// GitHubWebhook returns a WebhookConfig for GitHub-style signatures in the
+// X-Hub-Signature-256 header.
+func GitHubWebhook(secrets ...Secret) WebhookConfig {
+	return WebhookConfig{
+		Header:  "X-Hub-Signature-256",
+		Secrets: secrets,
+		Prefix:  "sha256=",
+	}
+}
+
+func (c WebhookConfig) withDefaults() WebhookConfig {
+	if c.Algorithm == "" {
+		c.Algorithm = WebhookSHA256
+	}
+
+	if c.Format == "" {
+		c.Format = WebhookPlain
+	}
+
+	if c.Tolerance <= 0 {
+		c.Tolerance = 5 * time.Minute
+	}
+
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 1 << 20
+	}
+
+	return c
+}
+
+// VerifyWebhook returns a middleware that verifies the HMAC signature of the
+// raw request body. Timestamped signatures outside Tolerance are rejected to
+// prevent replays. Failures return 401. The body is restored afterwards, so
+// Bind works as usual, and is also stored under RawBodyKey. It panics when
+// Header or Secrets are missing, or on an unknown Algorithm or Format.
+func VerifyWebhook(cfg WebhookConfig) MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	if cfg.Header == "" || !slices.ContainsFunc(cfg.Secrets, func(s Secret) bool { return !s.IsZero() }) {
+		panic("echoext: VerifyWebhook requires a Header and at least one secret")
+	}
+
+	newHash := webhookHashes[cfg.Algorithm]
+	if newHash == nil {
+		panic("echoext: unsupported webhook algorithm " + strconv.Quote(string(cfg.Algorithm)))
+	}
+
+	if cfg.Format != WebhookPlain && cfg.Format != WebhookStripe {
+		panic("echoext: unsupported webhook format " + strconv.Quote(string(cfg.Format)))
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, cfg.MaxBodyBytes))
+			if err != nil {
+				return bodyError(err)
+			}
+
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			c.Set(RawBodyKey, body)
+
+			timestamp, signatures := cfg.parseHeader(req)
+			if len(signatures) == 0 {
+				return errInvalidSignature
+			}
+
+			payload := body
+			if timestamp != "" {
+				if !cfg.fresh(timestamp) {
+					return errInvalidSignature
+				}
+
+				payload = append([]byte(timestamp+"."), body...)
+			}
+
+			if !cfg.verify(newHash, payload, signatures) {
+				return errInvalidSignature
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// parseHeader extracts the timestamp (if any) and the decoded candidate
+// signatures from the request. Malformed signatures are dropped.
+func (c WebhookConfig) parseHeader(req *http.Request) (string, [][]byte) {
+	value := req.Header.Get(c.Header)
+
+	if c.Format == WebhookStripe {
+		var timestamp string
+		var sigs [][]byte
+		for _, part := range strings.Split(value, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				timestamp = v
+			case "v1":
+				if sig, err := hex.DecodeString(v); err == nil {
+					sigs = append(sigs, sig)
+				}
+			}
+		}
+
+		if timestamp == "" {
+			return "", nil
+		}
+
+		return timestamp, sigs
+	}
+
+	raw, ok := strings.CutPrefix(value, c.Prefix)
+	if !ok || raw == "" {
+		return "", nil
+	}
+
+	decode := hex.DecodeString
+	if c.Base64 {
+		decode = base64.StdEncoding.DecodeString
+	}
+
+	sig, err := decode(raw)
+	if err != nil {
+		return "", nil
+	}
+
+	var timestamp string
+	if c.TimestampHeader != "" {
+		if timestamp = req.Header.Get(c.TimestampHeader); timestamp == "" {
+			return "", nil
+		}
+	}
+
+	return timestamp, [][]byte{sig}
+}
+
+// fresh reports whether the Unix timestamp is within Tolerance of now.
+func (c WebhookConfig) fresh(timestamp string) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(ts, 0))
+
+	return age <= c.Tolerance && age >= -c.Tolerance
+}
+
+// verify reports whether any signature matches the payload under any secret.
+func (c WebhookConfig) verify(newHash func() hash.Hash, payload []byte, signatures [][]byte) bool {
+	for _, secret := range c.Secrets {
+		key := secret.Value()
+		if key == "" {
+			continue
+		}
+
+		mac := hmac.New(newHash, []byte(key))
+		mac.Write(payload)
+		expected := mac.Sum(nil)
+
+		for _, sig := range signatures {
+			if hmac.Equal(expected, sig) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,259 @@
+package echoext
+
+import (
+	stdcontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const webhookBody = `{"event":"paid"}`
+
+// sign returns the HMAC of payload under secret.
+func sign(newHash func() hash.Hash, secret, payload string) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
+
+// serveWebhook posts webhookBody through VerifyWebhook(cfg) and returns the
+// status and the body the handler read.
+func serveWebhook(t *testing.T, cfg WebhookConfig, header http.Header) (int, string) {
+	t.Helper()
+
+	var got string
+
+	srv := New(ServerConfig{Environment: Production, MetricsConfig: MetricsConfig{Disabled: true}})
+	srv.Group("hooks", func(g *Group) {
+		g.POST("", func(c Context) error {
+			var body struct {
+				Event string `json:"event"`
+			}
+
+			if err := c.Bind(&body); err != nil {
+				return err
+			}
+
+			raw, _ := c.Get(RawBodyKey).([]byte)
+			got = body.Event + " " + string(raw)
+
+			return c.NoContent(http.StatusNoContent)
+		}, VerifyWebhook(cfg))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(webhookBody))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(rec, req)
+
+	return rec.Code, got
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+
+	hexSig := func(secret, payload string) string { return hex.EncodeToString(sign(sha256.New, secret, payload)) }
+
+	tests := []struct {
+		name   string
+		cfg    WebhookConfig
+		header http.Header
+		want   int
+	}{
+		{
+			name:   "plain hex",
+			cfg:    WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}},
+			header: http.Header{"X-Signature": {hexSig("s1", webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "plain base64 sha512",
+			cfg:    WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}, Algorithm: WebhookSHA512, Base64: true},
+			header: http.Header{"X-Signature": {base64.StdEncoding.EncodeToString(sign(sha512.New, "s1", webhookBody))}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "plain wrong secret",
+			cfg:    WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}},
+			header: http.Header{"X-Signature": {hexSig("other", webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name: "missing header",
+			cfg:  WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:   "github",
+			cfg:    GitHubWebhook(NewSecret("gh")),
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + hexSig("gh", webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "github without prefix",
+			cfg:    GitHubWebhook(NewSecret("gh")),
+			header: http.Header{"X-Hub-Signature-256": {hexSig("gh", webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "stripe",
+			cfg:    StripeWebhook(NewSecret("st")),
+			header: http.Header{"Stripe-Signature": {"t=" + now + ",v1=" + hexSig("st", now+"."+webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "stripe any v1 matches",
+			cfg:    StripeWebhook(NewSecret("st")),
+			header: http.Header{"Stripe-Signature": {"t=" + now + ",v1=" + hexSig("old", now+"."+webhookBody) + ",v1=" + hexSig("st", now+"."+webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "stripe stale timestamp",
+			cfg:    StripeWebhook(NewSecret("st")),
+			header: http.Header{"Stripe-Signature": {"t=" + stale + ",v1=" + hexSig("st", stale+"."+webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "stripe future timestamp",
+			cfg:    StripeWebhook(NewSecret("st")),
+			header: http.Header{"Stripe-Signature": {"t=" + future + ",v1=" + hexSig("st", future+"."+webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name: "stripe stale within tolerance",
+			cfg: func() WebhookConfig {
+				cfg := StripeWebhook(NewSecret("st"))
+				cfg.Tolerance = 15 * time.Minute
+				return cfg
+			}(),
+			header: http.Header{"Stripe-Signature": {"t=" + stale + ",v1=" + hexSig("st", stale+"."+webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "stripe timestamp not signed",
+			cfg:    StripeWebhook(NewSecret("st")),
+			header: http.Header{"Stripe-Signature": {"t=" + now + ",v1=" + hexSig("st", webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "plain timestamp header",
+			cfg:    WebhookConfig{Header: "X-Signature", TimestampHeader: "X-Timestamp", Secrets: []Secret{NewSecret("s1")}},
+			header: http.Header{"X-Signature": {hexSig("s1", now+"."+webhookBody)}, "X-Timestamp": {now}},
+			want:   http.StatusNoContent,
+		},
+		{
+			name:   "plain timestamp header missing",
+			cfg:    WebhookConfig{Header: "X-Signature", TimestampHeader: "X-Timestamp", Secrets: []Secret{NewSecret("s1")}},
+			header: http.Header{"X-Signature": {hexSig("s1", now+"."+webhookBody)}},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "rotation accepts the old secret",
+			cfg:    StripeWebhook(NewSecret("new"), NewSecret("old")),
+			header: http.Header{"Stripe-Signature": {"t=" + now + ",v1=" + hexSig("old", now+"."+webhookBody)}},
+			want:   http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveWebhook(t, tt.cfg, tt.header)
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+
+			// The handler binds the restored body and sees the raw bytes.
+			if want := "paid " + webhookBody; code == http.StatusNoContent && body != want {
+				t.Fatalf("handler read %q, want %q", body, want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookRotatedSecret(t *testing.T) {
+	var value atomic.Value
+	value.Store("v1")
+
+	store := NewSecretStore()
+	store.Register("test", SecretProviderFunc(func(stdcontext.Context, string) (string, error) {
+		return value.Load().(string), nil
+	}))
+
+	secret, err := store.Resolve("test://webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := GitHubWebhook(secret)
+	signed := func(key string) http.Header {
+		return http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(sign(sha256.New, key, webhookBody))}}
+	}
+
+	if code, _ := serveWebhook(t, cfg, signed("v1")); code != http.StatusNoContent {
+		t.Fatalf("before rotation: status %d", code)
+	}
+
+	value.Store("v2")
+	if err := store.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := serveWebhook(t, cfg, signed("v2")); code != http.StatusNoContent {
+		t.Fatalf("rotated secret: status %d", code)
+	}
+
+	if code, _ := serveWebhook(t, cfg, signed("v1")); code != http.StatusUnauthorized {
+		t.Fatalf("previous secret: status %d", code)
+	}
+}
+
+func TestVerifyWebhookBodyLimit(t *testing.T) {
+	cfg := WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}, MaxBodyBytes: 4}
+
+	code, _ := serveWebhook(t, cfg, http.Header{"X-Signature": {hex.EncodeToString(sign(sha256.New, "s1", webhookBody))}})
+	if code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestVerifyWebhookPanicsOnInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  WebhookConfig
+	}{
+		{name: "no header", cfg: WebhookConfig{Secrets: []Secret{NewSecret("s1")}}},
+		{name: "no secret", cfg: WebhookConfig{Header: "X-Signature"}},
+		{name: "empty secret", cfg: WebhookConfig{Header: "X-Signature", Secrets: []Secret{{}}}},
+		{name: "unknown algorithm", cfg: WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}, Algorithm: "sha3"}},
+		{name: "unknown format", cfg: WebhookConfig{Header: "X-Signature", Secrets: []Secret{NewSecret("s1")}, Format: "slack"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("VerifyWebhook did not panic")
+				}
+			}()
+
+			VerifyWebhook(tt.cfg)
+		})
+	}
+}